@@ -1,89 +1,130 @@
 package sdk
 
 import (
+	"context"
 	"database/sql"
-	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"fmt"
 
-	"github.com/go-sql-driver/mysql"
 	"github.com/sirupsen/logrus"
 )
 
-// DatabaseError is wraps a database-related error
-type DatabaseError struct {
-	Err error
-}
-
-// Error prints the error message related to database
-func (r *DatabaseError) Error() string {
-	return fmt.Sprintf("database error: %v", r.Err)
-}
-
 // Database is composed of a *sql.DB, logger and Database configuration
 type Database struct {
 	DatabaseConfig
 	logrus.FieldLogger
 	*sql.DB
+	// Replica is the connection to the read replicas of the database used by Retrieve, nil if reads are sent to DB
+	Replica                     *sql.DB
 	MaxConnectionAttempts       int
 	SleepingTimeBetweenAttempts time.Duration
+	// RetryPolicy is used between connection attempts, if nil MaxConnectionAttempts and SleepingTimeBetweenAttempts are used
+	RetryPolicy RetryPolicy
+	// lastUsed is the unix time in nanoseconds of the last operation on the Database
+	lastUsed atomic.Int64
+	// refreshCredentials resolves again the credentials of the Database and returns whether they changed, nil if not applicable
+	refreshCredentials func() (bool, error)
+	// stmtCache and replicaStmtCache are the prepared statement caches of DB and Replica, nil if disabled
+	stmtCacheMu      sync.RWMutex
+	stmtCache        *statementCache
+	replicaStmtCache *statementCache
+	// limits are the query limits enforced by the connections of the Database
+	limits QueryLimits
+	// tracing is whether the operations of the Database create spans, see EnableTracing
+	tracing atomic.Bool
+	// metrics records the metrics of the operations of the Database, nil if disabled
+	metrics *dbMetrics
+	// metricName is the name of the Database in its metrics, see metricDatabaseName
+	metricName string
 }
 
 // NewConnection opens a new sql.DB connection given the configuration, if the driver is not yet registered it gets registered
 func NewConnection(conf DatabaseConfig) (*sql.DB, error) {
-	if !IsRegistered(conf.DriverName()) {
-		sql.Register(conf.DriverName(), conf.Driver())
+	connector, err := newDSNConnector(conf)
+	if err != nil {
+		return nil, err
 	}
-	return sql.Open(conf.DriverName(), conf.DataSourceName())
+	return sql.OpenDB(connector), nil
 }
 
 // NewDatabase creates a new Database instance given configuration,connection, and parameters for connection attempts
 func NewDatabase(conf DatabaseConfig, connection *sql.DB, maxConnAttempts int, sleepingTimeBetweenAttempts time.Duration) (*Database, error) {
+	return NewDatabaseContext(context.Background(), conf, connection, maxConnAttempts, sleepingTimeBetweenAttempts)
+}
+
+// NewDatabaseContext creates a new Database instance like NewDatabase, the initial health-check is interrupted if ctx is done
+func NewDatabaseContext(ctx context.Context, conf DatabaseConfig, connection *sql.DB, maxConnAttempts int, sleepingTimeBetweenAttempts time.Duration) (*Database, error) {
+	return NewDatabaseWithRetryPolicy(ctx, conf, connection, ConstantBackOff{Interval: sleepingTimeBetweenAttempts, MaxRetries: maxConnAttempts})
+}
+
+// NewDatabaseWithRetryPolicy creates a new Database instance given configuration, connection, and the policy used between connection attempts
+func NewDatabaseWithRetryPolicy(ctx context.Context, conf DatabaseConfig, connection *sql.DB, policy RetryPolicy) (*Database, error) {
 	var err error
 	db := new(Database)
 	db.DB = connection
-	db.SleepingTimeBetweenAttempts = sleepingTimeBetweenAttempts
-	db.MaxConnectionAttempts = maxConnAttempts
+	db.RetryPolicy = policy
+	if constant, ok := policy.(ConstantBackOff); ok {
+		db.SleepingTimeBetweenAttempts = constant.Interval
+		db.MaxConnectionAttempts = constant.MaxRetries
+	}
 	db.DatabaseConfig = conf
+	db.touch()
 	db.FieldLogger = logrus.New().WithFields(logrus.Fields{
 		"db-driver": conf.DriverName(),
 		"db-name":   conf.Name(),
+		"db-dsn":    RedactedDataSourceName(conf),
 	})
-	err = db.WaitReady()
+	err = db.WaitReadyContext(ctx)
 	if err != nil {
-		return nil, &DatabaseError{Err: err}
+		return nil, NewDatabaseError(err)
 	}
 	return db, nil
 }
 
 // Store stores records in the Database.
-// sqlStatement must be in the form of "INSERT INTO xxx VALUES ($1, $2, ...) RETURNING id".
+// sqlStatement must be in the form of "INSERT INTO xxx VALUES ($1, $2, ...) RETURNING id", with the placeholders of the Dialect of the Database,
+// or "INSERT INTO xxx VALUES (?, ?, ...)" on databases without RETURNING support such as MySQL, in which case the AUTO_INCREMENT ID is returned.
+// Such statements can be built for any Dialect with Database.Builder.
 func (db *Database) Store(sqlStatement string, args ...interface{}) (id string, err error) {
+	return db.StoreContext(context.Background(), sqlStatement, args...)
+}
 
-	err = db.WaitReady()
+// StoreContext stores records in the Database, the statement is cancelled if ctx is done.
+// sqlStatement must be in the form of "INSERT INTO xxx VALUES ($1, $2, ...) RETURNING id".
+func (db *Database) StoreContext(ctx context.Context, sqlStatement string, args ...interface{}) (id string, err error) {
+	ctx, op := db.startOperation(ctx, "Store", sqlStatement)
+	defer func() { op.end(err) }()
+	err = db.waitReady(ctx, true)
 
 	if err != nil {
 		return
 	}
 
-	err = db.QueryRow(sqlStatement, args...).Scan(&id)
-	if err != nil {
-		return "", fmt.Errorf("recording record in db: %w", err)
-	}
-	return
+	return store(ctx, db.executor(), db.Dialect().SupportsReturning(), sqlStatement, args...)
 }
 
-// Update updates records in the Database.
-// sqlStatement must be in the form of "UPDATE xxx SET xxx WHERE xxx RETURNING id".
+// Update updates records in the Database and returns the ID of the first updated record, see UpdateResult for all of them.
+// sqlStatement must be in the form of "UPDATE xxx SET xxx WHERE xxx RETURNING id" (without RETURNING clause on MySQL).
 func (db *Database) Update(sqlStatement string, args ...interface{}) (id string, err error) {
-	err = db.WaitReady()
+	return db.UpdateContext(context.Background(), sqlStatement, args...)
+}
+
+// UpdateContext updates records in the Database, the statement is cancelled if ctx is done.
+// sqlStatement must be in the form of "UPDATE xxx SET xxx WHERE xxx RETURNING id".
+func (db *Database) UpdateContext(ctx context.Context, sqlStatement string, args ...interface{}) (id string, err error) {
+	ctx, op := db.startOperation(ctx, "Update", sqlStatement)
+	defer func() { op.end(err) }()
+	err = db.waitReady(ctx, true)
 	if err != nil {
 		return
 	}
-	err = db.QueryRow(sqlStatement, args...).Scan(&id)
+	id, err = update(ctx, db.executor(), db.Dialect().SupportsReturning(), sqlStatement, args...)
 	if err != nil {
-		return "", fmt.Errorf("updating record(s) in db: %w", err)
+		return
 	}
 	db.Debugf("correctly updated record with ID: %v", id)
 	return
@@ -92,54 +133,139 @@ func (db *Database) Update(sqlStatement string, args ...interface{}) (id string,
 // Retrieve retrieves records from the Database.
 // sqlStatement must be in the form of "SELECT xxx FROM xxx [WHERE xxx]".
 func (db *Database) Retrieve(sqlStatement string, args ...interface{}) (rows *sql.Rows, err error) {
-	err = db.WaitReady()
+	return db.RetrieveContext(context.Background(), sqlStatement, args...)
+}
+
+// RetrieveContext retrieves records from the Database, the returned rows are closed if ctx is done.
+// sqlStatement must be in the form of "SELECT xxx FROM xxx [WHERE xxx]".
+func (db *Database) RetrieveContext(ctx context.Context, sqlStatement string, args ...interface{}) (rows *sql.Rows, err error) {
+	ctx, op := db.startOperation(ctx, "Retrieve", sqlStatement)
+	defer func() { op.end(err) }()
+	err = db.waitReady(ctx, true)
 	if err != nil {
 		return
 	}
-	rows, err = db.Query(sqlStatement, args...)
-	if err != nil {
-		return nil, fmt.Errorf("retrieving record(s) from db: %w", err)
-	}
-	return
+	return retrieve(ctx, db.readExecutor(), sqlStatement, args...)
 }
 
-// Delete deletes records from the Database.
-// sqlStatement must be in the form of "DELETE FROM xxx WHERE xxx RETURN id".
+// Delete deletes records from the Database, sql.ErrNoRows is returned if no record matched (see DeleteResult).
+// sqlStatement must be in the form of "DELETE FROM xxx WHERE xxx RETURN id" (without RETURNING clause on MySQL).
 func (db *Database) Delete(sqlStatement string, args ...interface{}) (err error) {
-	err = db.WaitReady()
+	return db.DeleteContext(context.Background(), sqlStatement, args...)
+}
+
+// DeleteContext deletes records from the Database, the statement is cancelled if ctx is done.
+// sqlStatement must be in the form of "DELETE FROM xxx WHERE xxx RETURN id".
+func (db *Database) DeleteContext(ctx context.Context, sqlStatement string, args ...interface{}) (err error) {
+	ctx, op := db.startOperation(ctx, "Delete", sqlStatement)
+	defer func() { op.end(err) }()
+	err = db.waitReady(ctx, true)
 	if err != nil {
 		return
 	}
-	var id string
-	err = db.QueryRow(sqlStatement, args...).Scan(&id)
-	if err != nil {
-		return fmt.Errorf("deleting record(s) from db: %w", err)
-	}
-	return
+	return del(ctx, db.executor(), db.Dialect().SupportsReturning(), sqlStatement, args...)
 }
 
 // WaitReady performs a health-check of the Database and returns an error if the Database is unreachable
 func (db *Database) WaitReady() (err error) {
-	i := 0
-	for err = db.Ping(); err != nil && i < db.MaxConnectionAttempts; err = db.Ping() {
-		// Return directly if we encounter a MySQL error
-		if reflect.TypeOf(err) == reflect.TypeOf(&mysql.MySQLError{}) {
-			return err
+	return db.WaitReadyContext(context.Background())
+}
+
+// WaitReadyContext performs a health-check of the Database and returns an error if the Database is unreachable.
+// Failed attempts are retried according to the RetryPolicy of the Database unless the error is permanent (see IsPermanentError).
+// It stops retrying, including while sleeping between attempts, as soon as ctx is done.
+func (db *Database) WaitReadyContext(ctx context.Context) (err error) {
+	return db.waitReady(ctx, false)
+}
+
+// waitReady is WaitReadyContext, nested tells whether it is called by another operation of the Database,
+// in which case it is traced as a child span but its duration and errors are only measured by the calling operation.
+func (db *Database) waitReady(ctx context.Context, nested bool) (err error) {
+	ctx, op := db.startOperation(ctx, "WaitReady", "")
+	op.nested = nested
+	defer func() { op.end(err) }()
+	db.touch()
+	policy := db.retryPolicy()
+	start := time.Now()
+	credentialsRefreshed := false
+	for failedAttempts := 1; ; failedAttempts++ {
+		err = db.PingContext(ctx)
+		if err == nil {
+			if failedAttempts > 1 {
+				// the statements may have been prepared on connections to a server which is gone
+				db.resetStatementCache()
+			}
+			return nil
+		}
+
+		// Return directly if the context is done or the error cannot be solved by retrying (e.g. wrong credentials)
+		if ctx.Err() != nil {
+			return NewDatabaseError(fmt.Errorf("unable to connect to %v: %w", db.Name(), ctx.Err()))
+		}
+		if IsAuthenticationError(err) && db.refreshCredentials != nil && !credentialsRefreshed {
+			// the credentials may have been rotated, retry right away if they changed
+			credentialsRefreshed = true
+			changed, refreshErr := db.refreshCredentials()
+			if refreshErr != nil {
+				db.FieldLogger.Warnf("refreshing credentials of DB %v: %v", db.Name(), refreshErr)
+			} else if changed {
+				db.FieldLogger.Infof("credentials of DB %v were rotated, reconnecting", db.Name())
+				op.retry(failedAttempts, 0, err)
+				continue
+			}
+		}
+		if IsPermanentError(err) {
+			return NewDatabaseError(fmt.Errorf("unable to connect to %v: %w", db.Name(), err))
 		}
 
 		// Otherwise, keep retrying (could be e.g. *net.OpError)
-		db.FieldLogger.Warn(fmt.Errorf("impossible to connect to DB: %v. trying again in: %v seconds, error: %w", db.Name(), db.SleepingTimeBetweenAttempts, err))
-		time.Sleep(db.SleepingTimeBetweenAttempts)
-		i++
+		backoff, ok := policy.NextBackOff(failedAttempts, time.Since(start))
+		if !ok {
+			return NewDatabaseError(fmt.Errorf("unable to connect to %v: %w", db.Name(), err))
+		}
+		db.FieldLogger.Warn(fmt.Errorf("impossible to connect to DB: %v. trying again in: %v, error: %w", db.Name(), backoff, err))
+		op.retry(failedAttempts, backoff, err)
+		if sleepErr := sleepContext(ctx, backoff); sleepErr != nil {
+			return NewDatabaseError(fmt.Errorf("unable to connect to %v: %w", db.Name(), sleepErr))
+		}
 	}
-	if err != nil {
-		return fmt.Errorf("unable to connect to %v: %w", db.Name(), err)
+}
+
+// retryPolicy returns the RetryPolicy of the Database, falling back to a constant one built from its connection attempts parameters
+func (db *Database) retryPolicy() RetryPolicy {
+	if db.RetryPolicy != nil {
+		return db.RetryPolicy
 	}
-	return
+	return ConstantBackOff{Interval: db.SleepingTimeBetweenAttempts, MaxRetries: db.MaxConnectionAttempts}
+}
+
+// LastUsed returns the time of the last operation performed through the Database helpers
+func (db *Database) LastUsed() time.Time {
+	return time.Unix(0, db.lastUsed.Load())
 }
 
-// Close closes the sql.DB connection
+// touch records that the Database is being used
+func (db *Database) touch() {
+	db.lastUsed.Store(time.Now().UnixNano())
+}
+
+// reader returns the connection used for reads, to the read replicas if any
+func (db *Database) reader() *sql.DB {
+	if db.Replica != nil {
+		return db.Replica
+	}
+	return db.DB
+}
+
+// Close closes the sql.DB connection, and the one to the read replicas if any
 func (db *Database) Close() error {
+	db.EnableStatementCache(0)
+	if db.Replica != nil {
+		if err := db.Replica.Close(); err != nil {
+			_ = db.DB.Close()
+			return err
+		}
+	}
 	return db.DB.Close()
 }
 
@@ -158,3 +284,98 @@ func IsRegistered(driver string) bool {
 	}
 	return false
 }
+
+// Executor is the set of statement execution methods shared by *sql.DB, *sql.Tx and *sql.Conn
+type Executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// store runs an insert statement and returns the ID of the inserted record.
+// Without RETURNING support, the statement is executed and the ID is the one generated for an AUTO_INCREMENT column.
+func store(ctx context.Context, q Executor, returning bool, sqlStatement string, args ...interface{}) (id string, err error) {
+	if !returning {
+		id, _, err = execWrite(ctx, q, sqlStatement, args...)
+	} else {
+		err = q.QueryRowContext(ctx, sqlStatement, args...).Scan(&id)
+	}
+	if err != nil {
+		return "", NewDatabaseError(fmt.Errorf("recording record in db: %w", err))
+	}
+	return
+}
+
+// update runs an update statement and returns the ID of the first updated record.
+// Without RETURNING support, sql.ErrNoRows is returned if no record matched, and the ID is the value given to LAST_INSERT_ID(), if any.
+func update(ctx context.Context, q Executor, returning bool, sqlStatement string, args ...interface{}) (id string, err error) {
+	if !returning {
+		var affected int64
+		id, affected, err = execWrite(ctx, q, sqlStatement, args...)
+		if err == nil && affected == 0 {
+			err = sql.ErrNoRows
+		}
+	} else {
+		err = q.QueryRowContext(ctx, sqlStatement, args...).Scan(&id)
+	}
+	if err != nil {
+		return "", NewDatabaseError(fmt.Errorf("updating record(s) in db: %w", err))
+	}
+	return
+}
+
+func retrieve(ctx context.Context, q Executor, sqlStatement string, args ...interface{}) (rows *sql.Rows, err error) {
+	rows, err = q.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("retrieving record(s) from db: %w", err))
+	}
+	return
+}
+
+// del runs a delete statement, sql.ErrNoRows is returned if no record matched
+func del(ctx context.Context, q Executor, returning bool, sqlStatement string, args ...interface{}) (err error) {
+	if !returning {
+		var affected int64
+		_, affected, err = execWrite(ctx, q, sqlStatement, args...)
+		if err == nil && affected == 0 {
+			err = sql.ErrNoRows
+		}
+	} else {
+		var id string
+		err = q.QueryRowContext(ctx, sqlStatement, args...).Scan(&id)
+	}
+	if err != nil {
+		return NewDatabaseError(fmt.Errorf("deleting record(s) from db: %w", err))
+	}
+	return
+}
+
+// execWrite executes a write statement without "RETURNING" clause and returns the last inserted ID and the number of affected rows
+func execWrite(ctx context.Context, q Executor, sqlStatement string, args ...interface{}) (id string, affected int64, err error) {
+	res, err := q.ExecContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return
+	}
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+	affected, err = res.RowsAffected()
+	if err != nil {
+		return
+	}
+	recordRowsAffected(ctx, affected)
+	return strconv.FormatInt(lastID, 10), affected, nil
+}
+
+// sleepContext pauses the current goroutine for duration d, it returns ctx.Err() early if ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,252 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// DefaultBulkInsertBatchSize is the number of rows inserted per statement by BulkInsert when none is configured
+	DefaultBulkInsertBatchSize = 500
+	// maxStatementParameters is the maximum number of parameters of a multi-row INSERT statement,
+	// the lowest limit of the supported databases (SQLite)
+	maxStatementParameters = 32766
+)
+
+// RowSource provides the rows inserted by BulkInsert
+type RowSource interface {
+	// Next should return the values of the next row, or io.EOF once there are no more rows
+	Next() ([]interface{}, error)
+}
+
+// RowSourceFunc is a function implementing RowSource
+type RowSourceFunc func() ([]interface{}, error)
+
+// Next calls f
+func (f RowSourceFunc) Next() ([]interface{}, error) {
+	return f()
+}
+
+// RowsFromSlice returns a RowSource of rows
+func RowsFromSlice(rows [][]interface{}) RowSource {
+	i := 0
+	return RowSourceFunc(func() ([]interface{}, error) {
+		if i >= len(rows) {
+			return nil, io.EOF
+		}
+		i++
+		return rows[i-1], nil
+	})
+}
+
+// BulkInsertOptions holds the options of BulkInsert
+type BulkInsertOptions struct {
+	// BatchSize is the number of rows sent per statement (or per COPY command on Postgres), DefaultBulkInsertBatchSize if 0
+	BatchSize int
+	// Progress, if set, is called with the total number of inserted rows after each batch has been executed by the database
+	Progress func(inserted int64)
+}
+
+// BulkInsert inserts all the rows of source into the columns of table in a single transaction, and returns the number of inserted rows.
+// Rows are copied with COPY on Postgres and inserted with multi-row INSERT statements on other databases.
+// If opts is nil, the rows are sent DefaultBulkInsertBatchSize at a time.
+func (db *Database) BulkInsert(ctx context.Context, table string, columns []string, source RowSource, opts *BulkInsertOptions) (inserted int64, err error) {
+	// a failed bulk insert cannot be retried as the rows of source were consumed
+	err = db.WithTx(ctx, &TxOptions{}, func(tx *Tx) error {
+		inserted, err = tx.BulkInsert(table, columns, source, opts)
+		return err
+	})
+	if err != nil {
+		return 0, NewDatabaseError(err)
+	}
+	return
+}
+
+// BulkInsert inserts all the rows of source into the columns of table as part of the transaction, and returns the number of inserted rows
+func (tx *Tx) BulkInsert(table string, columns []string, source RowSource, opts *BulkInsertOptions) (inserted int64, err error) {
+	if opts == nil {
+		opts = &BulkInsertOptions{}
+	}
+	if len(columns) == 0 {
+		return 0, fmt.Errorf("bulk inserting into %v: no columns", table)
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBulkInsertBatchSize
+	}
+
+	var b bulkInserter
+	if _, ok := tx.db.Dialect().(PostgresDialect); ok {
+		b, err = newCopyInserter(tx.ctx, tx.Tx, table, columns)
+	} else {
+		if maxRows := maxStatementParameters / len(columns); batchSize > maxRows {
+			batchSize = maxRows
+		}
+		b = &multiRowInserter{ctx: tx.ctx, tx: tx.Tx, dialect: tx.db.Dialect(), table: table, columns: columns}
+	}
+	if err != nil {
+		return 0, fmt.Errorf("bulk inserting into %v: %w", table, err)
+	}
+
+	pending := 0
+	for {
+		row, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return inserted, fmt.Errorf("reading row %v: %w", inserted+int64(pending)+1, err)
+		}
+		if len(row) != len(columns) {
+			return inserted, fmt.Errorf("row %v has %v values instead of %v", inserted+int64(pending)+1, len(row), len(columns))
+		}
+		if err = b.add(row); err != nil {
+			return inserted, fmt.Errorf("bulk inserting into %v: %w", table, err)
+		}
+
+		if pending++; pending == batchSize {
+			if err = b.flush(); err != nil {
+				return inserted, fmt.Errorf("bulk inserting into %v: %w", table, err)
+			}
+			inserted += int64(pending)
+			pending = 0
+			if opts.Progress != nil {
+				opts.Progress(inserted)
+			}
+		}
+	}
+
+	if err = b.close(); err != nil {
+		return inserted, fmt.Errorf("bulk inserting into %v: %w", table, err)
+	}
+	if pending > 0 {
+		inserted += int64(pending)
+		if opts.Progress != nil {
+			opts.Progress(inserted)
+		}
+	}
+	tx.db.Debugf("bulk inserted %v rows into %v", inserted, table)
+	return inserted, nil
+}
+
+// bulkInserter sends rows to the database
+type bulkInserter interface {
+	// add adds a row to the current batch
+	add(row []interface{}) error
+	// flush sends the current batch
+	flush() error
+	// close sends the current batch, if any, and ends the insertion
+	close() error
+}
+
+// copyInserter is a bulkInserter copying rows with the postgres COPY command, each batch being copied by its own command
+type copyInserter struct {
+	ctx           context.Context
+	tx            *sql.Tx
+	copyStatement string
+	// stmt is the COPY command of the current batch, nil until the first row of the batch is added
+	stmt *sql.Stmt
+}
+
+// newCopyInserter prepares the copy of columns into table, which may be schema-qualified
+func newCopyInserter(ctx context.Context, tx *sql.Tx, table string, columns []string) (*copyInserter, error) {
+	copyStatement := pq.CopyIn(table, columns...)
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		copyStatement = pq.CopyInSchema(schema, name, columns...)
+	}
+	c := &copyInserter{ctx: ctx, tx: tx, copyStatement: copyStatement}
+	// the first COPY command is started right away to report invalid tables or columns before reading any row
+	if err := c.start(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// start starts the COPY command of a new batch
+func (c *copyInserter) start() (err error) {
+	c.stmt, err = c.tx.PrepareContext(c.ctx, c.copyStatement)
+	return err
+}
+
+func (c *copyInserter) add(row []interface{}) error {
+	if c.stmt == nil {
+		if err := c.start(); err != nil {
+			return err
+		}
+	}
+	_, err := c.stmt.ExecContext(c.ctx, row...)
+	return err
+}
+
+// flush ends the COPY command of the current batch, the driver only buffering the copied rows until then
+func (c *copyInserter) flush() error {
+	if c.stmt == nil {
+		return nil
+	}
+	stmt := c.stmt
+	c.stmt = nil
+	// executing the statement without arguments ends the copy
+	if _, err := stmt.ExecContext(c.ctx); err != nil {
+		_ = stmt.Close()
+		return err
+	}
+	return stmt.Close()
+}
+
+func (c *copyInserter) close() error {
+	return c.flush()
+}
+
+// multiRowInserter is a bulkInserter sending rows as multi-row INSERT statements
+type multiRowInserter struct {
+	ctx     context.Context
+	tx      *sql.Tx
+	dialect Dialect
+	table   string
+	columns []string
+	args    []interface{}
+}
+
+func (m *multiRowInserter) add(row []interface{}) error {
+	m.args = append(m.args, row...)
+	return nil
+}
+
+func (m *multiRowInserter) flush() error {
+	if len(m.args) == 0 {
+		return nil
+	}
+	columns := make([]string, len(m.columns))
+	for i, column := range m.columns {
+		columns[i] = m.dialect.QuoteIdentifier(column)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("INSERT INTO " + quoteQualifiedIdentifier(m.dialect, m.table) + " (" + strings.Join(columns, ", ") + ") VALUES ")
+	for i := range m.args {
+		switch {
+		case i == 0:
+			sb.WriteString("(")
+		case i%len(m.columns) == 0:
+			sb.WriteString("), (")
+		default:
+			sb.WriteString(", ")
+		}
+		sb.WriteString(m.dialect.Placeholder(i + 1))
+	}
+	sb.WriteString(")")
+
+	_, err := m.tx.ExecContext(m.ctx, sb.String(), m.args...)
+	m.args = m.args[:0]
+	return err
+}
+
+func (m *multiRowInserter) close() error {
+	return m.flush()
+}
@@ -0,0 +1,56 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestBulkInsert(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 0)
+	columns := []string{"name", "age", "weight", "height"}
+
+	const count = 1234
+	i := 0
+	source := sdk.RowSourceFunc(func() ([]interface{}, error) {
+		if i == count {
+			return nil, io.EOF
+		}
+		i++
+		return []interface{}{name, i, weight, height}, nil
+	})
+	progress := make([]int64, 0)
+	inserted, err := db.BulkInsert(context.Background(), "patients", columns, source, &sdk.BulkInsertOptions{
+		BatchSize: 500,
+		Progress:  func(inserted int64) { progress = append(progress, inserted) },
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(count), inserted)
+	require.Equal(t, []int64{500, 1000, count}, progress)
+
+	var total, sum int64
+	require.NoError(t, db.QueryRow("SELECT COUNT(*), SUM(age) FROM patients").Scan(&total, &sum))
+	require.Equal(t, int64(count), total)
+	require.Equal(t, int64(count*(count+1)/2), sum)
+
+	// a failure rolls back all the inserted rows
+	errSource := errors.New("source failure")
+	i = 0
+	failing := sdk.RowSourceFunc(func() ([]interface{}, error) {
+		if i == 10 {
+			return nil, errSource
+		}
+		i++
+		return []interface{}{name, i, weight, height}, nil
+	})
+	_, err = db.BulkInsert(context.Background(), "patients", columns, failing, &sdk.BulkInsertOptions{BatchSize: 3})
+	require.ErrorIs(t, err, errSource)
+	_, err = db.BulkInsert(context.Background(), "patients", columns, sdk.RowsFromSlice([][]interface{}{{name}}), nil)
+	require.Error(t, err)
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM patients").Scan(&total))
+	require.Equal(t, int64(count), total)
+}
@@ -1,17 +1,26 @@
 package sdk
 
 import (
+	"database/sql"
 	"database/sql/driver"
-	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/lib/pq"
 	"github.com/mattn/go-sqlite3"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk/credentials"
 )
 
 // DatabaseConfig is a general interface for specific-db configs, from the specific config, the driver name
 // can be retrieved as well as the datasource name to connect to the db and a function to register the actual driver
 type DatabaseConfig interface {
-	// DriverName should return the name of the driver for example "postgres" or "sqlite3"
+	// DriverName should return the name of the driver for example "postgres", "sqlite3" or "mysql"
 	DriverName() string
 	// DataSourceName should return the connection string to the db: postgres example "host=localhost port=5432 user=test password=test dbname=test sslmode=disable"
 	DataSourceName() string
@@ -21,10 +30,89 @@ type DatabaseConfig interface {
 	Name() string
 }
 
+// PooledDatabaseConfig is implemented by DatabaseConfig defining their own connection pool settings,
+// which take precedence over the ones of the DBManagerConfig
+type PooledDatabaseConfig interface {
+	PoolSettings() PoolConfig
+}
+
+// PoolConfig holds the connection pool settings of a database.
+// Zero values keep the database/sql defaults, a negative MaxIdleConns disables idle connections.
+type PoolConfig struct {
+	MaxOpenConns           int `yaml:"db-max-open-conns" default:"0"`
+	MaxIdleConns           int `yaml:"db-max-idle-conns" default:"0"`
+	ConnMaxLifetimeSeconds int `yaml:"db-conn-max-lifetime" default:"0"`
+	ConnMaxIdleTimeSeconds int `yaml:"db-conn-max-idle-time" default:"0"`
+}
+
+// PoolSettings returns the pool settings themselves, so that a PoolConfig embedded in a DatabaseConfig implements PooledDatabaseConfig
+func (pc PoolConfig) PoolSettings() PoolConfig {
+	return pc
+}
+
+// Merge returns the pool settings of pc overridden by the non-zero settings of override
+func (pc PoolConfig) Merge(override PoolConfig) PoolConfig {
+	if override.MaxOpenConns != 0 {
+		pc.MaxOpenConns = override.MaxOpenConns
+	}
+	if override.MaxIdleConns != 0 {
+		pc.MaxIdleConns = override.MaxIdleConns
+	}
+	if override.ConnMaxLifetimeSeconds != 0 {
+		pc.ConnMaxLifetimeSeconds = override.ConnMaxLifetimeSeconds
+	}
+	if override.ConnMaxIdleTimeSeconds != 0 {
+		pc.ConnMaxIdleTimeSeconds = override.ConnMaxIdleTimeSeconds
+	}
+	return pc
+}
+
+// Apply sets the non-zero pool settings on conn
+func (pc PoolConfig) Apply(conn *sql.DB) {
+	if pc.MaxOpenConns != 0 {
+		conn.SetMaxOpenConns(pc.MaxOpenConns)
+	}
+	if pc.MaxIdleConns != 0 {
+		conn.SetMaxIdleConns(pc.MaxIdleConns)
+	}
+	if pc.ConnMaxLifetimeSeconds != 0 {
+		conn.SetConnMaxLifetime(time.Duration(pc.ConnMaxLifetimeSeconds) * time.Second)
+	}
+	if pc.ConnMaxIdleTimeSeconds != 0 {
+		conn.SetConnMaxIdleTime(time.Duration(pc.ConnMaxIdleTimeSeconds) * time.Second)
+	}
+}
+
+// SQLite database modes
+const (
+	// SQLiteModeFile opens the database file for reading and writing, creating it if it does not exist
+	SQLiteModeFile = "file"
+	// SQLiteModeMemory opens an in-memory database shared (unless Cache is "private") by all connections of the process using the same Database name
+	SQLiteModeMemory = "memory"
+	// SQLiteModeReadOnly opens the database file for reading only
+	SQLiteModeReadOnly = "read-only"
+	// SQLiteModeImmutable opens a read-only database file which is assumed to not be modified by any other process
+	SQLiteModeImmutable = "immutable"
+)
+
 // SQLiteConfig is the configuration when using the sqlite driver
 type SQLiteConfig struct {
 	Database  string `yaml:"db-database" default:"test"`
 	Directory string `yaml:"db-directory" default:"db/"`
+
+	// Mode is one of "file", "memory", "read-only" or "immutable", "file" is used if left empty
+	Mode string `yaml:"db-mode" default:"file"`
+	// JournalMode is the journal mode of the database, e.g. "WAL" or "DELETE", the sqlite default is used if left empty
+	JournalMode string `yaml:"db-journal-mode" default:""`
+	// BusyTimeoutMilliseconds is how long a connection waits for a lock held by another one before failing with SQLITE_BUSY
+	BusyTimeoutMilliseconds int `yaml:"db-busy-timeout-ms" default:"0"`
+	// ForeignKeys enables the enforcement of foreign key constraints
+	ForeignKeys bool `yaml:"db-foreign-keys" default:"false"`
+	// Cache is either "shared" or "private", the sqlite default is used if left empty
+	Cache string `yaml:"db-cache" default:""`
+
+	PoolConfig  `yaml:",inline"`
+	QueryLimits `yaml:",inline"`
 }
 
 // DriverName returns the name of the driver which is sqlite3
@@ -32,9 +120,52 @@ func (conf SQLiteConfig) DriverName() string {
 	return "sqlite3"
 }
 
-// DataSourceName should return the connection string to the db: <directory>/<database>.db
+// DataSourceName should return the connection string to the db: <directory>/<database>.db,
+// or a "file:" URI when the mode or any option is set, e.g. file:<directory>/<database>.db?_journal_mode=WAL&mode=ro
 func (conf SQLiteConfig) DataSourceName() string {
-	return conf.Directory + "/" + conf.Database + ".db"
+	params := url.Values{}
+	path := filepath.Join(conf.Directory, conf.Database+".db")
+
+	switch conf.Mode {
+	case SQLiteModeMemory:
+		path = conf.Database
+		params.Set("mode", "memory")
+		if conf.Cache == "" {
+			params.Set("cache", "shared")
+		}
+	case SQLiteModeReadOnly:
+		params.Set("mode", "ro")
+	case SQLiteModeImmutable:
+		params.Set("mode", "ro")
+		params.Set("immutable", "1")
+	}
+	if conf.Cache != "" {
+		params.Set("cache", conf.Cache)
+	}
+	if conf.JournalMode != "" {
+		params.Set("_journal_mode", conf.JournalMode)
+	}
+	if conf.BusyTimeoutMilliseconds > 0 {
+		params.Set("_busy_timeout", strconv.Itoa(conf.BusyTimeoutMilliseconds))
+	}
+	if conf.ForeignKeys {
+		params.Set("_foreign_keys", "1")
+	}
+
+	if len(params) == 0 {
+		return path
+	}
+	return "file:" + (&url.URL{Path: filepath.ToSlash(path)}).EscapedPath() + "?" + params.Encode()
+}
+
+// RedactedDataSourceName returns the connection string to the db, which contains no secret
+func (conf SQLiteConfig) RedactedDataSourceName() string {
+	return conf.DataSourceName()
+}
+
+// ConnectionIdentity returns the connection string to the db, which identifies the connection
+func (conf SQLiteConfig) ConnectionIdentity() string {
+	return conf.DataSourceName()
 }
 
 // Driver returns the appropriate database driver
@@ -47,6 +178,11 @@ func (conf SQLiteConfig) Name() string {
 	return conf.Database
 }
 
+// Dialect returns the SQLite Dialect
+func (conf SQLiteConfig) Dialect() Dialect {
+	return SQLiteDialect{}
+}
+
 // PostgresConfig is the configuration when using the postgres driver
 type PostgresConfig struct {
 	Host     string `yaml:"db-host" default:"localhost"`
@@ -54,6 +190,40 @@ type PostgresConfig struct {
 	Database string `yaml:"db-database" default:"test"`
 	User     string `yaml:"db-user" default:"postgres"`
 	Password string `yaml:"db-pwd" default:"password"`
+
+	// SSLMode is one of "disable", "require", "verify-ca" or "verify-full", "disable" is used if left empty
+	SSLMode string `yaml:"db-sslmode" default:"disable"`
+	// SSLRootCert is the path to the certificate authority used to verify the server certificate
+	SSLRootCert string `yaml:"db-sslrootcert" default:""`
+	// SSLCert and SSLKey are the paths to the client certificate and key
+	SSLCert string `yaml:"db-sslcert" default:""`
+	SSLKey  string `yaml:"db-sslkey" default:""`
+
+	// ConnectTimeoutSeconds is the maximum wait for a connection, 0 means no timeout
+	ConnectTimeoutSeconds int    `yaml:"db-connect-timeout" default:"0"`
+	ApplicationName       string `yaml:"db-application-name" default:""`
+	// SearchPath is the comma-separated list of schemas searched for unqualified names, e.g. "cohorts,public"
+	SearchPath string `yaml:"db-search-path" default:""`
+	// StatementTimeoutMilliseconds aborts any statement taking longer, 0 means no timeout
+	StatementTimeoutMilliseconds int `yaml:"db-statement-timeout-ms" default:"0"`
+
+	// Hosts lists the servers of the database as "host" or "host:port" (Port being used if omitted), it replaces Host if set.
+	// Connections are opened to the first available server matching TargetSessionAttrs, so that the database fails over to another server.
+	Hosts []string `yaml:"db-hosts"`
+	// TargetSessionAttrs is one of "any", "read-write", "read-only", "primary", "standby" or "prefer-standby",
+	// like the libpq parameter target_session_attrs. "any" is used if left empty, or "read-write" if ReadFromReplicas is set.
+	TargetSessionAttrs string `yaml:"db-target-session-attrs" default:""`
+	// ReadFromReplicas routes the statements of Retrieve to the standby servers (or to the primary if none is available),
+	// while the other statements are sent to a server matching TargetSessionAttrs
+	ReadFromReplicas bool `yaml:"db-read-from-replicas" default:"false"`
+
+	// CredentialsID is the ID of the credentials in the DBManager credentials provider, which replace User and Password if set
+	CredentialsID string `yaml:"db-credentials-id" default:""`
+	// connectionString is the resolved connection string, it replaces the whole configuration if set
+	connectionString string
+
+	PoolConfig  `yaml:",inline"`
+	QueryLimits `yaml:",inline"`
 }
 
 // DriverName returns "postgres"
@@ -61,10 +231,159 @@ func (conf PostgresConfig) DriverName() string {
 	return "postgres"
 }
 
-// DataSourceName returns the connection string to a postgres db: "host=localhost port=5432 user=test password=test dbname=test sslmode=disable"
+// DataSourceName returns the connection string to a postgres db: "host=localhost port=5432 user=test password=test dbname=test sslmode=disable",
+// values containing spaces, quotes or backslashes are quoted and escaped.
 func (conf PostgresConfig) DataSourceName() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		conf.Host, conf.Port, conf.User, conf.Password, conf.Database)
+	if conf.connectionString != "" {
+		return conf.connectionString
+	}
+	return conf.dataSourceName(conf.Password)
+}
+
+// RedactedDataSourceName returns the connection string to the postgres db with the password redacted
+func (conf PostgresConfig) RedactedDataSourceName() string {
+	if conf.connectionString != "" {
+		return redactDataSourceName(conf.connectionString)
+	}
+	if conf.Password == "" {
+		return conf.dataSourceName("")
+	}
+	return conf.dataSourceName(redactedSecret)
+}
+
+// ConnectionIdentity returns the parameters identifying the connection, i.e. all of them except the password
+func (conf PostgresConfig) ConnectionIdentity() string {
+	conf.Password = ""
+	identity := conf.RedactedDataSourceName() + " credentials_id=" + quotePostgresValue(conf.CredentialsID)
+	if conf.ReadFromReplicas {
+		identity += " read_from_replicas=true"
+	}
+	return identity
+}
+
+// dataSourceName returns the connection string to the postgres db built from the configuration and the given password.
+// With several hosts, the hosts and ports are comma-separated like in libpq connection strings.
+func (conf PostgresConfig) dataSourceName(password string) string {
+	hosts, ports := conf.hostsAndPorts()
+	params := conf.params(strings.Join(hosts, ","), strings.Join(ports, ","), password)
+	if targetSessionAttrs := conf.targetSessionAttrs(); targetSessionAttrs != TargetSessionAny {
+		params = append(params, [2]string{"target_session_attrs", targetSessionAttrs})
+	}
+	return joinPostgresParams(params)
+}
+
+// failoverHosts returns the address and connection string of each host of the database
+func (conf PostgresConfig) failoverHosts() []failoverHost {
+	if conf.connectionString != "" {
+		return nil
+	}
+	hosts, ports := conf.hostsAndPorts()
+	failoverHosts := make([]failoverHost, len(hosts))
+	for i := range hosts {
+		failoverHosts[i] = failoverHost{
+			address: net.JoinHostPort(hosts[i], ports[i]),
+			dsn:     joinPostgresParams(conf.params(hosts[i], ports[i], conf.Password)),
+		}
+	}
+	return failoverHosts
+}
+
+// targetSessionAttrs returns the target session attributes of the connections to the database
+func (conf PostgresConfig) targetSessionAttrs() string {
+	if conf.connectionString != "" {
+		return TargetSessionAny
+	}
+	if conf.TargetSessionAttrs == "" || conf.TargetSessionAttrs == TargetSessionAny {
+		if conf.ReadFromReplicas {
+			return TargetSessionReadWrite
+		}
+		return TargetSessionAny
+	}
+	return conf.TargetSessionAttrs
+}
+
+// readReplicaConfig returns the configuration connecting to the standby servers if ReadFromReplicas is set
+func (conf PostgresConfig) readReplicaConfig() DatabaseConfig {
+	if !conf.ReadFromReplicas || conf.connectionString != "" {
+		return nil
+	}
+	conf.ReadFromReplicas = false
+	conf.TargetSessionAttrs = TargetSessionPreferStandby
+	return conf
+}
+
+// hostsAndPorts returns the hosts of the database and their ports
+func (conf PostgresConfig) hostsAndPorts() (hosts, ports []string) {
+	if len(conf.Hosts) == 0 {
+		return []string{conf.Host}, []string{strconv.Itoa(conf.Port)}
+	}
+	for _, address := range conf.Hosts {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			// no port, the host may still be a bracketed IPv6 address
+			host, port = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]"), strconv.Itoa(conf.Port)
+		}
+		hosts = append(hosts, host)
+		ports = append(ports, port)
+	}
+	return
+}
+
+// params returns the parameters of a connection string to the given host, port and password
+func (conf PostgresConfig) params(host, port, password string) [][2]string {
+	sslMode := conf.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	params := [][2]string{
+		{"host", host},
+		{"port", port},
+		{"user", conf.User},
+		{"password", password},
+		{"dbname", conf.Database},
+		{"sslmode", sslMode},
+	}
+	optionalParams := [][2]string{
+		{"sslrootcert", conf.SSLRootCert},
+		{"sslcert", conf.SSLCert},
+		{"sslkey", conf.SSLKey},
+		{"application_name", conf.ApplicationName},
+		{"search_path", conf.SearchPath},
+	}
+	if conf.ConnectTimeoutSeconds > 0 {
+		optionalParams = append(optionalParams, [2]string{"connect_timeout", strconv.Itoa(conf.ConnectTimeoutSeconds)})
+	}
+	if conf.StatementTimeoutMilliseconds > 0 {
+		optionalParams = append(optionalParams, [2]string{"statement_timeout", strconv.Itoa(conf.StatementTimeoutMilliseconds)})
+	}
+	for _, param := range optionalParams {
+		if param[1] != "" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// joinPostgresParams returns the connection string of params
+func joinPostgresParams(params [][2]string) string {
+	dsn := make([]string, 0, len(params))
+	for _, param := range params {
+		dsn = append(dsn, param[0]+"="+quotePostgresValue(param[1]))
+	}
+	return strings.Join(dsn, " ")
+}
+
+// GetCredentialsID returns the ID of the credentials to connect with
+func (conf PostgresConfig) GetCredentialsID() string {
+	return conf.CredentialsID
+}
+
+// WithCredentials returns a copy of the configuration connecting with the username, password or connection string of creds
+func (conf PostgresConfig) WithCredentials(creds *credentials.Credentials) DatabaseConfig {
+	conf.User = valueOr(creds.Username(), conf.User)
+	conf.Password = valueOr(creds.Password(), conf.Password)
+	conf.connectionString = creds.ConnectionString()
+	return conf
 }
 
 // Driver Should returns the postgres database driver
@@ -76,3 +395,135 @@ func (conf PostgresConfig) Driver() driver.Driver {
 func (conf PostgresConfig) Name() string {
 	return conf.Database
 }
+
+// Dialect returns the Postgres Dialect
+func (conf PostgresConfig) Dialect() Dialect {
+	return PostgresDialect{}
+}
+
+// MySQLConfig is the configuration when using the MySQL driver, it can also be used to connect to MariaDB
+type MySQLConfig struct {
+	Host     string `yaml:"db-host" default:"localhost"`
+	Port     int    `yaml:"db-port" default:"3306"`
+	Database string `yaml:"db-database" default:"test"`
+	User     string `yaml:"db-user" default:"root"`
+	Password string `yaml:"db-pwd" default:"password"`
+	// TLS is either "false", "true", "skip-verify", "preferred" or the name of a configuration registered with mysql.RegisterTLSConfig
+	TLS       string `yaml:"db-tls" default:"false"`
+	Charset   string `yaml:"db-charset" default:"utf8mb4"`
+	ParseTime bool   `yaml:"db-parse-time" default:"true"`
+	// timeouts are in seconds, 0 means no timeout
+	DialTimeoutSeconds  int `yaml:"db-dial-timeout" default:"10"`
+	ReadTimeoutSeconds  int `yaml:"db-read-timeout" default:"0"`
+	WriteTimeoutSeconds int `yaml:"db-write-timeout" default:"0"`
+
+	// CredentialsID is the ID of the credentials in the DBManager credentials provider, which replace User and Password if set
+	CredentialsID string `yaml:"db-credentials-id" default:""`
+	// connectionString is the resolved connection string, it replaces the whole configuration if set
+	connectionString string
+
+	PoolConfig  `yaml:",inline"`
+	QueryLimits `yaml:",inline"`
+}
+
+// DriverName returns "mysql"
+func (conf MySQLConfig) DriverName() string {
+	return "mysql"
+}
+
+// DataSourceName returns the connection string to a MySQL db: "user:password@tcp(localhost:3306)/test?charset=utf8mb4&parseTime=true"
+func (conf MySQLConfig) DataSourceName() string {
+	if conf.connectionString != "" {
+		return conf.connectionString
+	}
+	return conf.dataSourceName(conf.Password)
+}
+
+// RedactedDataSourceName returns the connection string to the MySQL db with the password redacted
+func (conf MySQLConfig) RedactedDataSourceName() string {
+	if conf.connectionString != "" {
+		cfg, err := mysql.ParseDSN(conf.connectionString)
+		if err != nil {
+			return redactDataSourceName(conf.connectionString)
+		}
+		if cfg.Passwd != "" {
+			cfg.Passwd = redactedSecret
+		}
+		return cfg.FormatDSN()
+	}
+	if conf.Password == "" {
+		return conf.dataSourceName("")
+	}
+	return conf.dataSourceName(redactedSecret)
+}
+
+// ConnectionIdentity returns the parameters identifying the connection, i.e. all of them except the password
+func (conf MySQLConfig) ConnectionIdentity() string {
+	conf.Password = ""
+	return conf.RedactedDataSourceName() + " credentials_id=" + quotePostgresValue(conf.CredentialsID)
+}
+
+// dataSourceName returns the connection string to the MySQL db built from the configuration and the given password
+func (conf MySQLConfig) dataSourceName(password string) string {
+	cfg := mysql.NewConfig()
+	cfg.User = conf.User
+	cfg.Passwd = password
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(conf.Host, strconv.Itoa(conf.Port))
+	cfg.DBName = conf.Database
+	cfg.TLSConfig = conf.TLS
+	cfg.ParseTime = conf.ParseTime
+	cfg.Timeout = time.Duration(conf.DialTimeoutSeconds) * time.Second
+	cfg.ReadTimeout = time.Duration(conf.ReadTimeoutSeconds) * time.Second
+	cfg.WriteTimeout = time.Duration(conf.WriteTimeoutSeconds) * time.Second
+	// report the number of matched rather than changed rows, as other databases do
+	cfg.ClientFoundRows = true
+	if conf.Charset != "" {
+		cfg.Params = map[string]string{"charset": conf.Charset}
+	}
+	return cfg.FormatDSN()
+}
+
+// GetCredentialsID returns the ID of the credentials to connect with
+func (conf MySQLConfig) GetCredentialsID() string {
+	return conf.CredentialsID
+}
+
+// WithCredentials returns a copy of the configuration connecting with the username, password or connection string of creds
+func (conf MySQLConfig) WithCredentials(creds *credentials.Credentials) DatabaseConfig {
+	conf.User = valueOr(creds.Username(), conf.User)
+	conf.Password = valueOr(creds.Password(), conf.Password)
+	conf.connectionString = creds.ConnectionString()
+	return conf
+}
+
+// Driver returns the MySQL database driver
+func (conf MySQLConfig) Driver() driver.Driver {
+	return &mysql.MySQLDriver{}
+}
+
+// Name returns the name of the connected database
+func (conf MySQLConfig) Name() string {
+	return conf.Database
+}
+
+// Dialect returns the MySQL Dialect
+func (conf MySQLConfig) Dialect() Dialect {
+	return MySQLDialect{}
+}
+
+// quotePostgresValue quotes a value of a postgres connection string if it is empty or contains spaces, quotes or backslashes
+func quotePostgresValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, "'\\") && strings.IndexFunc(value, unicode.IsSpace) < 0 {
+		return value
+	}
+	return "'" + strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(value) + "'"
+}
+
+// valueOr returns value, or fallback if value is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
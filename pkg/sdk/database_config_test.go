@@ -0,0 +1,190 @@
+package sdk_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk/credentials"
+)
+
+func TestMySQLConfig(t *testing.T) {
+	conf := sdk.MySQLConfig{
+		Host:               "db.example.com",
+		Port:               3307,
+		Database:           "cohorts",
+		User:               "ti",
+		Password:           "p@ss word/?",
+		TLS:                "skip-verify",
+		Charset:            "utf8mb4",
+		ParseTime:          true,
+		DialTimeoutSeconds: 5,
+	}
+	require.Equal(t, "mysql", conf.DriverName())
+	require.Equal(t, "cohorts", conf.Name())
+
+	parsed, err := mysql.ParseDSN(conf.DataSourceName())
+	require.NoError(t, err)
+	require.Equal(t, "ti", parsed.User)
+	require.Equal(t, "p@ss word/?", parsed.Passwd)
+	require.Equal(t, "db.example.com:3307", parsed.Addr)
+	require.Equal(t, "cohorts", parsed.DBName)
+	require.Equal(t, "skip-verify", parsed.TLSConfig)
+	require.Equal(t, "utf8mb4", parsed.Params["charset"])
+	require.True(t, parsed.ParseTime)
+	require.True(t, parsed.ClientFoundRows)
+	require.Equal(t, "5s", parsed.Timeout.String())
+
+	// credentials IDs are quoted in the identity like in postgres connection strings
+	conf.CredentialsID = "mysql creds"
+	require.True(t, strings.HasSuffix(conf.ConnectionIdentity(), ` credentials_id='mysql creds'`))
+	other := conf
+	other.CredentialsID = "'mysql creds'"
+	require.NotEqual(t, conf.ConnectionIdentity(), other.ConnectionIdentity())
+}
+
+func TestPostgresConfig(t *testing.T) {
+	conf := sdk.PostgresConfig{
+		Host:     "localhost",
+		Port:     5432,
+		Database: "test",
+		User:     "test",
+		Password: "test",
+	}
+	require.Equal(t, "host=localhost port=5432 user=test password=test dbname=test sslmode=disable", conf.DataSourceName())
+
+	conf.Password = `it's a \secret`
+	conf.SSLMode = "verify-full"
+	conf.SSLRootCert = "/certs/root ca.crt"
+	conf.SSLCert = "/certs/client.crt"
+	conf.SSLKey = "/certs/client.key"
+	conf.ConnectTimeoutSeconds = 10
+	conf.ApplicationName = "ti-note"
+	conf.SearchPath = "cohorts,public"
+	conf.StatementTimeoutMilliseconds = 30000
+	require.Equal(t, `host=localhost port=5432 user=test password='it\'s a \\secret' dbname=test sslmode=verify-full `+
+		`sslrootcert='/certs/root ca.crt' sslcert=/certs/client.crt sslkey=/certs/client.key application_name=ti-note `+
+		`search_path=cohorts,public connect_timeout=10 statement_timeout=30000`, conf.DataSourceName())
+
+	_, err := pq.NewConnector(conf.DataSourceName())
+	require.NoError(t, err)
+}
+
+func TestPostgresMultiHostConfig(t *testing.T) {
+	conf := sdk.PostgresConfig{
+		Hosts:              []string{"primary", "replica:5433", "[::1]"},
+		Port:               5432,
+		Database:           "test",
+		User:               "test",
+		TargetSessionAttrs: sdk.TargetSessionPrimary,
+	}
+	require.Equal(t, "host=primary,replica,::1 port=5432,5433,5432 user=test password='' dbname=test sslmode=disable target_session_attrs=primary",
+		conf.DataSourceName())
+
+	// reads routed to replicas require the other statements to be sent to a writable server
+	conf.TargetSessionAttrs = ""
+	conf.ReadFromReplicas = true
+	require.Contains(t, conf.DataSourceName(), "target_session_attrs=read-write")
+	noReplicas := conf
+	noReplicas.ReadFromReplicas = false
+	noReplicas.TargetSessionAttrs = sdk.TargetSessionReadWrite
+	require.NotEqual(t, sdk.ConnectionID(conf), sdk.ConnectionID(noReplicas))
+}
+
+func TestPostgresFailoverUnreachable(t *testing.T) {
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{MaxConnectionAttempts: 0})
+	_, err := manager.NewDatabase(sdk.PostgresConfig{
+		Hosts:              []string{"127.0.0.1:1", "127.0.0.1:2"},
+		Database:           "test",
+		TargetSessionAttrs: sdk.TargetSessionReadWrite,
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "127.0.0.1:2")
+}
+
+func TestSQLiteConfig(t *testing.T) {
+	conf := sdk.SQLiteConfig{Directory: "db/", Database: "test"}
+	require.Equal(t, "db/test.db", conf.DataSourceName())
+
+	conf.Mode = sdk.SQLiteModeReadOnly
+	conf.JournalMode = "WAL"
+	conf.BusyTimeoutMilliseconds = 5000
+	conf.ForeignKeys = true
+	require.Equal(t, "file:db/test.db?_busy_timeout=5000&_foreign_keys=1&_journal_mode=WAL&mode=ro", conf.DataSourceName())
+
+	conf = sdk.SQLiteConfig{Directory: "my db", Database: "test#1", Mode: sdk.SQLiteModeImmutable}
+	require.Equal(t, "file:my%20db/test%231.db?immutable=1&mode=ro", conf.DataSourceName())
+
+	conf = sdk.SQLiteConfig{Database: "shared", Mode: sdk.SQLiteModeMemory}
+	require.Equal(t, "file:shared?cache=shared&mode=memory", conf.DataSourceName())
+}
+
+func TestSQLiteMemoryAndReadOnly(t *testing.T) {
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{})
+
+	// all connections to a shared in-memory database see the same data
+	memDB, err := manager.NewDatabase(sdk.SQLiteConfig{Database: "memory-test", Mode: sdk.SQLiteModeMemory})
+	require.NoError(t, err)
+	memDB.SetMaxOpenConns(2)
+	_, err = memDB.Exec(createQuery)
+	require.NoError(t, err)
+	conn, err := memDB.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = memDB.Exec(insertQuery, name, age, weight, height)
+	require.NoError(t, err)
+	var count int
+	require.NoError(t, conn.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM patients").Scan(&count))
+	require.Equal(t, 1, count)
+
+	// a read-only database cannot be written to
+	dir := t.TempDir()
+	rwDB, err := manager.NewDatabase(sdk.SQLiteConfig{Directory: dir, Database: "test", JournalMode: "WAL", BusyTimeoutMilliseconds: 1000})
+	require.NoError(t, err)
+	_, err = rwDB.Exec(createQuery)
+	require.NoError(t, err)
+	roDB, err := manager.NewDatabase(sdk.SQLiteConfig{Directory: dir, Database: "test", Mode: sdk.SQLiteModeReadOnly})
+	require.NoError(t, err)
+	_, err = roDB.Exec(insertQuery, name, age, weight, height)
+	require.Error(t, err)
+	require.NoError(t, manager.CloseAll())
+}
+
+func TestPostgresConfigWithCredentials(t *testing.T) {
+	conf := sdk.PostgresConfig{Host: "localhost", Port: 5432, Database: "test", CredentialsID: "pg-creds"}
+	require.Equal(t, "pg-creds", conf.GetCredentialsID())
+
+	resolved := conf.WithCredentials(credentials.NewCredentials("ti", "rotated secret", ""))
+	require.Equal(t, "host=localhost port=5432 user=ti password='rotated secret' dbname=test sslmode=disable", resolved.DataSourceName())
+	require.Equal(t, "host=localhost port=5432 user='' password='' dbname=test sslmode=disable", conf.DataSourceName())
+
+	resolved = conf.WithCredentials(credentials.NewCredentials("", "", "postgres://ti:secret@db:5432/test"))
+	require.Equal(t, "postgres://ti:secret@db:5432/test", resolved.DataSourceName())
+}
+
+func TestConnectionIdentityAndRedaction(t *testing.T) {
+	conf := sdk.PostgresConfig{Host: "localhost", Port: 5432, Database: "test", User: "ti", Password: "first secret"}
+	rotated := conf
+	rotated.Password = "second secret"
+	otherHost := conf
+	otherHost.Host = "replica"
+
+	require.Equal(t, sdk.ConnectionID(conf), sdk.ConnectionID(rotated))
+	require.NotEqual(t, sdk.ConnectionID(conf), sdk.ConnectionID(otherHost))
+	require.NotEqual(t, sdk.ConnectionID(conf), sdk.ConnectionID(sdk.MySQLConfig{Host: "localhost", Port: 5432, Database: "test", User: "ti"}))
+	require.Len(t, sdk.ConnectionID(conf), 64)
+	otherPool := conf
+	otherPool.PoolConfig = sdk.PoolConfig{MaxOpenConns: 2}
+	require.NotEqual(t, sdk.ConnectionID(conf), sdk.ConnectionID(otherPool))
+
+	require.Equal(t, "host=localhost port=5432 user=ti password=xxxxx dbname=test sslmode=disable", sdk.RedactedDataSourceName(conf))
+	require.NotContains(t, sdk.RedactedDataSourceName(sdk.MySQLConfig{Host: "localhost", Port: 3306, User: "ti", Password: "secret"}), "secret")
+	resolved := conf.WithCredentials(credentials.NewCredentials("", "", "postgres://ti:secret@db:5432/test?sslmode=require"))
+	require.Equal(t, "postgres://ti:xxxxx@db:5432/test?sslmode=require", sdk.RedactedDataSourceName(resolved))
+	resolved = conf.WithCredentials(credentials.NewCredentials("", "", "host=h password=a:b@c dbname=test"))
+	require.Equal(t, "host=h password=xxxxx dbname=test", sdk.RedactedDataSourceName(resolved))
+}
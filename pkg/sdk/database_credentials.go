@@ -0,0 +1,182 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk/credentials"
+)
+
+// CredentialedDatabaseConfig is implemented by DatabaseConfig whose credentials can be stored in a credentials.Provider
+// instead of the configuration itself. They are resolved by the DBManager when connecting to the database,
+// and resolved again if the database later rejects them, e.g. because the secret was rotated.
+type CredentialedDatabaseConfig interface {
+	DatabaseConfig
+	// GetCredentialsID should return the ID of the credentials in the provider, or "" if the configuration holds its own credentials
+	GetCredentialsID() string
+	// WithCredentials should return a copy of the configuration connecting with the given credentials
+	WithCredentials(creds *credentials.Credentials) DatabaseConfig
+}
+
+// SetCredentialsProvider sets the provider used to resolve the credentials of CredentialedDatabaseConfig
+func (m *DBManager) SetCredentialsProvider(cp credentials.Provider) {
+	m.Lock()
+	defer m.Unlock()
+	m.credentialsProvider = cp
+}
+
+// resolveConfig returns the configuration to connect with, i.e. config with its credentials resolved if it references any
+func (m *DBManager) resolveConfig(config DatabaseConfig) (DatabaseConfig, error) {
+	cc, ok := config.(CredentialedDatabaseConfig)
+	if !ok || cc.GetCredentialsID() == "" {
+		return config, nil
+	}
+
+	m.Lock()
+	cp := m.credentialsProvider
+	m.Unlock()
+	if cp == nil {
+		return nil, fmt.Errorf("no credentials provider to resolve credentials %v of database %v", cc.GetCredentialsID(), config.Name())
+	}
+	creds, err := cp.GetCredentials(cc.GetCredentialsID())
+	if err != nil {
+		return nil, fmt.Errorf("resolving credentials of database %v: %w", config.Name(), err)
+	}
+	return cc.WithCredentials(creds), nil
+}
+
+// credentialsRefresher returns a function resolving again the credentials of config and updating the connectors if they changed,
+// or nil if config does not reference credentials. replica is the connector to the read replicas of the database, if any.
+func (m *DBManager) credentialsRefresher(config DatabaseConfig, connector, replica *dsnConnector) func() (bool, error) {
+	if cc, ok := config.(CredentialedDatabaseConfig); !ok || cc.GetCredentialsID() == "" {
+		return nil
+	}
+	return func() (bool, error) {
+		resolved, err := m.resolveConfig(config)
+		if err != nil {
+			return false, err
+		}
+		if replica != nil {
+			replica.SetConfig(replicaConfig(resolved))
+		}
+		return connector.SetConfig(resolved), nil
+	}
+}
+
+// dsnConnector is a driver.Connector to the database of a configuration, which can be changed for the connections opened afterwards.
+// If the configuration lists several hosts, connections are opened to the first one available and matching its target session attributes.
+type dsnConnector struct {
+	driver driver.Driver
+
+	mu    sync.RWMutex
+	dsn   string
+	hosts []hostConnector
+	// targetSessionAttrs are the target session attributes of the hosts, "any" or "" if connecting to a single host
+	targetSessionAttrs string
+	// preferred is the index of the host to try first, the last one connected to
+	preferred atomic.Int64
+	// limits are enforced on the statements of the connections if enabled, they must be set before the connector is shared
+	limits QueryLimits
+}
+
+// hostConnector is the connector to one of the hosts of a dsnConnector
+type hostConnector struct {
+	address string
+	driver.Connector
+}
+
+// newDSNConnector returns a connector to the database described by conf using the driver registered under its name
+func newDSNConnector(conf DatabaseConfig) (*dsnConnector, error) {
+	if !IsRegistered(conf.DriverName()) {
+		sql.Register(conf.DriverName(), conf.Driver())
+	}
+	// retrieve the driver registered under the name, which may differ from conf.Driver() (e.g. custom connection hooks)
+	registered, err := sql.Open(conf.DriverName(), hostDataSourceNames(conf)[0].dsn)
+	if err != nil {
+		return nil, err
+	}
+	c := &dsnConnector{driver: registered.Driver(), limits: queryLimitsOf(conf)}
+	if err := registered.Close(); err != nil {
+		return nil, err
+	}
+	c.setConfig(conf)
+	return c, nil
+}
+
+// Connect opens a connection with the current configuration
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	c.mu.RLock()
+	hosts, targetSessionAttrs := c.hosts, c.targetSessionAttrs
+	c.mu.RUnlock()
+	var conn driver.Conn
+	var err error
+	if len(hosts) == 1 && (targetSessionAttrs == "" || targetSessionAttrs == TargetSessionAny) {
+		conn, err = hosts[0].Connect(ctx)
+	} else {
+		conn, err = c.connectFailover(ctx, hosts, targetSessionAttrs)
+	}
+	if err != nil || !c.limits.enabled() {
+		return conn, err
+	}
+	return &limitedConn{Conn: conn, limits: c.limits}, nil
+}
+
+// Driver returns the underlying driver
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// SetConfig sets the configuration used by new connections and returns whether their data source name changed
+func (c *dsnConnector) SetConfig(conf DatabaseConfig) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.dsn == conf.DataSourceName() {
+		return false
+	}
+	c.setConfig(conf)
+	return true
+}
+
+// setConfig sets the configuration, the connector must be locked or not yet shared
+func (c *dsnConnector) setConfig(conf DatabaseConfig) {
+	c.dsn = conf.DataSourceName()
+	c.targetSessionAttrs = ""
+	if fc, ok := conf.(failoverDatabaseConfig); ok {
+		c.targetSessionAttrs = fc.targetSessionAttrs()
+	}
+
+	hosts := hostDataSourceNames(conf)
+	c.hosts = make([]hostConnector, len(hosts))
+	for i, host := range hosts {
+		c.hosts[i] = hostConnector{address: host.address, Connector: dsnDriverConnector{driver: c.driver, dsn: host.dsn}}
+		if dc, ok := c.driver.(driver.DriverContext); ok {
+			// an invalid data source name is reported by Connect through Open
+			if connector, err := dc.OpenConnector(host.dsn); err == nil {
+				c.hosts[i].Connector = connector
+			}
+		}
+	}
+	if int(c.preferred.Load()) >= len(c.hosts) {
+		c.preferred.Store(0)
+	}
+}
+
+// dsnDriverConnector is a driver.Connector opening connections with driver.Open, for drivers which do not implement driver.DriverContext
+type dsnDriverConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+// Connect opens a connection to the data source name
+func (c dsnDriverConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver returns the underlying driver
+func (c dsnDriverConnector) Driver() driver.Driver {
+	return c.driver
+}
@@ -0,0 +1,193 @@
+package sdk
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect describes the SQL syntax differences between databases, so that plugins can build statements working on any of them
+type Dialect interface {
+	// Name should return the name of the dialect, e.g. "postgres"
+	Name() string
+	// Placeholder should return the placeholder of the n-th (starting at 1) statement parameter, e.g. "$1" or "?"
+	Placeholder(n int) string
+	// QuoteIdentifier should return name quoted as an identifier (table, column, ...), escaping any quote it contains
+	QuoteIdentifier(name string) string
+	// LimitOffset should return the clause restricting the rows of a query, limit is ignored if negative and offset if 0.
+	// It returns "" if there is no restriction.
+	LimitOffset(limit, offset int64) string
+	// SupportsReturning should return whether INSERT, UPDATE and DELETE statements can have a "RETURNING" clause
+	SupportsReturning() bool
+	// BooleanLiteral should return the SQL literal of b
+	BooleanLiteral(b bool) string
+	// TimestampLiteral should return the SQL literal of t
+	TimestampLiteral(t time.Time) string
+}
+
+// DialectDatabaseConfig is implemented by DatabaseConfig exposing the Dialect of their database
+type DialectDatabaseConfig interface {
+	Dialect() Dialect
+}
+
+// DialectOf returns the Dialect of the database configured by conf, inferred from its driver name if it does not expose one
+func DialectOf(conf DatabaseConfig) Dialect {
+	if dc, ok := conf.(DialectDatabaseConfig); ok {
+		return dc.Dialect()
+	}
+	switch conf.DriverName() {
+	case "sqlite3":
+		return SQLiteDialect{}
+	case "mysql":
+		return MySQLDialect{}
+	}
+	return PostgresDialect{}
+}
+
+// PostgresDialect is the Dialect of PostgreSQL
+type PostgresDialect struct{}
+
+// Name returns "postgres"
+func (PostgresDialect) Name() string {
+	return "postgres"
+}
+
+// Placeholder returns "$n"
+func (PostgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+// QuoteIdentifier returns name between double quotes
+func (PostgresDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`)
+}
+
+// LimitOffset returns a "LIMIT n OFFSET m" clause
+func (PostgresDialect) LimitOffset(limit, offset int64) string {
+	return limitOffset(limit, offset, "ALL")
+}
+
+// SupportsReturning returns true
+func (PostgresDialect) SupportsReturning() bool {
+	return true
+}
+
+// BooleanLiteral returns TRUE or FALSE
+func (PostgresDialect) BooleanLiteral(b bool) string {
+	return strings.ToUpper(strconv.FormatBool(b))
+}
+
+// TimestampLiteral returns a "TIMESTAMP WITH TIME ZONE" literal of t
+func (PostgresDialect) TimestampLiteral(t time.Time) string {
+	return "TIMESTAMP WITH TIME ZONE '" + t.Format("2006-01-02 15:04:05.999999Z07:00") + "'"
+}
+
+// SQLiteDialect is the Dialect of SQLite
+type SQLiteDialect struct{}
+
+// Name returns "sqlite3"
+func (SQLiteDialect) Name() string {
+	return "sqlite3"
+}
+
+// Placeholder returns "?"
+func (SQLiteDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// QuoteIdentifier returns name between double quotes
+func (SQLiteDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`)
+}
+
+// LimitOffset returns a "LIMIT n OFFSET m" clause, SQLite requiring a negative limit to only skip rows
+func (SQLiteDialect) LimitOffset(limit, offset int64) string {
+	return limitOffset(limit, offset, "-1")
+}
+
+// SupportsReturning returns true, RETURNING clauses being supported since SQLite 3.35
+func (SQLiteDialect) SupportsReturning() bool {
+	return true
+}
+
+// BooleanLiteral returns 1 or 0, SQLite having no boolean type
+func (SQLiteDialect) BooleanLiteral(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// TimestampLiteral returns t as a string in the format in which the sqlite driver stores times
+func (SQLiteDialect) TimestampLiteral(t time.Time) string {
+	return "'" + t.Format(sqlite3TimestampFormat) + "'"
+}
+
+// MySQLDialect is the Dialect of MySQL
+type MySQLDialect struct{}
+
+// Name returns "mysql"
+func (MySQLDialect) Name() string {
+	return "mysql"
+}
+
+// Placeholder returns "?"
+func (MySQLDialect) Placeholder(n int) string {
+	return "?"
+}
+
+// QuoteIdentifier returns name between backticks
+func (MySQLDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`")
+}
+
+// LimitOffset returns a "LIMIT n OFFSET m" clause, MySQL requiring the maximum limit to only skip rows
+func (MySQLDialect) LimitOffset(limit, offset int64) string {
+	return limitOffset(limit, offset, "18446744073709551615")
+}
+
+// SupportsReturning returns false as MySQL statements cannot have a "RETURNING" clause
+func (MySQLDialect) SupportsReturning() bool {
+	return false
+}
+
+// BooleanLiteral returns TRUE or FALSE
+func (MySQLDialect) BooleanLiteral(b bool) string {
+	return strings.ToUpper(strconv.FormatBool(b))
+}
+
+// TimestampLiteral returns a TIMESTAMP literal of t in UTC, MySQL literals having no time zone
+func (MySQLDialect) TimestampLiteral(t time.Time) string {
+	return "TIMESTAMP '" + t.UTC().Format("2006-01-02 15:04:05.999999") + "'"
+}
+
+// sqlite3TimestampFormat is the format in which the sqlite driver stores time.Time values
+const sqlite3TimestampFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// quoteIdentifier returns name between quote, doubling the quotes it contains
+func quoteIdentifier(name, quote string) string {
+	return quote + strings.ReplaceAll(name, quote, quote+quote) + quote
+}
+
+// limitOffset returns a "LIMIT n OFFSET m" clause, using noLimit as limit if only an offset is given
+func limitOffset(limit, offset int64, noLimit string) string {
+	clause := ""
+	if limit >= 0 {
+		clause = "LIMIT " + strconv.FormatInt(limit, 10)
+	} else if offset > 0 {
+		clause = "LIMIT " + noLimit
+	}
+	if offset > 0 {
+		clause += " OFFSET " + strconv.FormatInt(offset, 10)
+	}
+	return clause
+}
+
+// quoteQualifiedIdentifier quotes each dot-separated part of name, e.g. a schema-qualified table name
+func quoteQualifiedIdentifier(d Dialect, name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = d.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,265 @@
+package sdk
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// limitedConn is a driver.Conn enforcing QueryLimits on the statements it runs.
+// It forwards the optional driver interfaces to the underlying connection, and falls back to the database/sql defaults when not implemented.
+type limitedConn struct {
+	driver.Conn
+	limits QueryLimits
+}
+
+// UnwrapDriverConn returns the connection of the database driver, e.g. *sqlite3.SQLiteConn or the postgres connection,
+// from the driverConn given by sql.Conn.Raw, which wraps it when the database has query limits (see QueryLimits)
+func UnwrapDriverConn(driverConn interface{}) interface{} {
+	if c, ok := driverConn.(interface{ Unwrap() driver.Conn }); ok {
+		return c.Unwrap()
+	}
+	return driverConn
+}
+
+// Unwrap returns the connection of the database driver
+func (c *limitedConn) Unwrap() driver.Conn {
+	return c.Conn
+}
+
+// Prepare prepares a statement
+func (c *limitedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// PrepareContext prepares a statement
+func (c *limitedConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	if pc, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = pc.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &limitedStmt{Stmt: stmt, conn: c}, nil
+}
+
+// BeginTx starts a transaction
+func (c *limitedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts)
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("the driver does not support transaction options")
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without BeginTx
+}
+
+// ExecContext executes a statement within the query timeout
+func (c *limitedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel, timeoutSet := c.limits.withTimeout(ctx)
+	defer cancel()
+	res, err := ec.ExecContext(ctx, query, args)
+	return res, c.limits.timeoutError(ctx, timeoutSet, err)
+}
+
+// QueryContext runs a query whose rows are limited
+func (c *limitedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, cancel, timeoutSet := c.limits.withTimeout(ctx)
+	rows, err := qc.QueryContext(ctx, query, args)
+	if err != nil {
+		cancel()
+		return nil, c.limits.timeoutError(ctx, timeoutSet, err)
+	}
+	return newLimitedRows(ctx, cancel, timeoutSet, c.limits, rows), nil
+}
+
+// Ping checks the connection
+func (c *limitedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession resets the connection before its reuse
+func (c *limitedConn) ResetSession(ctx context.Context) error {
+	if sr, ok := c.Conn.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid returns whether the connection can be reused
+func (c *limitedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+// CheckNamedValue converts the statement arguments
+func (c *limitedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// limitedStmt is a driver.Stmt enforcing the QueryLimits of its connection
+type limitedStmt struct {
+	driver.Stmt
+	conn *limitedConn
+}
+
+// ExecContext executes the statement within the query timeout
+func (s *limitedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	limits := s.conn.limits
+	ctx, cancel, timeoutSet := limits.withTimeout(ctx)
+	defer cancel()
+
+	var res driver.Result
+	var err error
+	if ec, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = ec.ExecContext(ctx, args)
+	} else if err = ctx.Err(); err == nil {
+		res, err = s.Stmt.Exec(namedValuesToValues(args)) //nolint:staticcheck // fallback for drivers without ExecContext
+	}
+	return res, limits.timeoutError(ctx, timeoutSet, err)
+}
+
+// QueryContext runs the statement, whose rows are limited
+func (s *limitedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	limits := s.conn.limits
+	ctx, cancel, timeoutSet := limits.withTimeout(ctx)
+
+	var rows driver.Rows
+	var err error
+	if qc, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = qc.QueryContext(ctx, args)
+	} else if err = ctx.Err(); err == nil {
+		rows, err = s.Stmt.Query(namedValuesToValues(args)) //nolint:staticcheck // fallback for drivers without QueryContext
+	}
+	if err != nil {
+		cancel()
+		return nil, limits.timeoutError(ctx, timeoutSet, err)
+	}
+	return newLimitedRows(ctx, cancel, timeoutSet, limits, rows), nil
+}
+
+// CheckNamedValue converts the statement arguments with the checker of the statement, or else of the connection
+func (s *limitedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return s.conn.CheckNamedValue(nv)
+}
+
+// limitedRows are driver.Rows stopping with a *QueryLimitError once they exceed their limits
+type limitedRows struct {
+	driver.Rows
+	ctx        context.Context
+	cancel     context.CancelFunc
+	timeoutSet bool
+	limiter    resultLimiter
+}
+
+// newLimitedRows wraps rows read within ctx, cancel is called once they are closed
+func newLimitedRows(ctx context.Context, cancel context.CancelFunc, timeoutSet bool, limits QueryLimits, rows driver.Rows) *limitedRows {
+	return &limitedRows{Rows: rows, ctx: ctx, cancel: cancel, timeoutSet: timeoutSet, limiter: resultLimiter{limits: limits}}
+}
+
+// Next reads the next row
+func (r *limitedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == io.EOF {
+		return err
+	}
+	if err != nil {
+		return r.limiter.limits.timeoutError(r.ctx, r.timeoutSet, err)
+	}
+	return r.limiter.add(dest)
+}
+
+// Close closes the rows and releases their context
+func (r *limitedRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// ColumnTypeScanType returns the Go type of the values of a column
+func (r *limitedRows) ColumnTypeScanType(index int) reflect.Type {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return ct.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+// ColumnTypeDatabaseTypeName returns the database type of a column
+func (r *limitedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return ct.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+// ColumnTypeLength returns the length of a column of variable length type
+func (r *limitedRows) ColumnTypeLength(index int) (int64, bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return ct.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+// ColumnTypeNullable returns whether a column may be NULL
+func (r *limitedRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return ct.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+// ColumnTypePrecisionScale returns the precision and scale of a decimal column
+func (r *limitedRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if ct, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return ct.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+// HasNextResultSet returns whether there is another result set
+func (r *limitedRows) HasNextResultSet() bool {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+// NextResultSet advances to the next result set, whose rows are limited separately
+func (r *limitedRows) NextResultSet() error {
+	if rs, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		r.limiter = resultLimiter{limits: r.limiter.limits}
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+// namedValuesToValues returns the values of args, for drivers without context support
+func namedValuesToValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
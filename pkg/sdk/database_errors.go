@@ -0,0 +1,242 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrorCategory is the kind of failure of a DatabaseError
+type ErrorCategory string
+
+const (
+	// ErrorCategoryOther is the category of the errors which could not be classified
+	ErrorCategoryOther ErrorCategory = "other"
+	// ErrorCategoryConnection is the category of errors reaching the database, e.g. a refused or lost connection
+	ErrorCategoryConnection ErrorCategory = "connection"
+	// ErrorCategoryTimeout is the category of statements which timed out or were interrupted, e.g. waiting for a lock
+	ErrorCategoryTimeout ErrorCategory = "timeout"
+	// ErrorCategoryConstraint is the category of integrity constraint violations, e.g. a duplicate key or a NULL in a NOT NULL column
+	ErrorCategoryConstraint ErrorCategory = "constraint"
+	// ErrorCategorySyntax is the category of invalid statements, e.g. syntax errors or unknown tables and columns
+	ErrorCategorySyntax ErrorCategory = "syntax"
+	// ErrorCategoryPermission is the category of authentication failures and of statements denied by the database
+	ErrorCategoryPermission ErrorCategory = "permission"
+	// ErrorCategoryNotFound is the category of statements which matched no record (sql.ErrNoRows) and of unknown databases
+	ErrorCategoryNotFound ErrorCategory = "not-found"
+	// ErrorCategorySerialization is the category of concurrent transactions conflicts, e.g. serialization failures and deadlocks
+	ErrorCategorySerialization ErrorCategory = "serialization"
+)
+
+// Sentinel errors matched with errors.Is by the DatabaseError of each category
+var (
+	ErrConnection    = errors.New("database connection error")
+	ErrTimeout       = errors.New("database timeout")
+	ErrConstraint    = errors.New("database constraint violation")
+	ErrSyntax        = errors.New("invalid database statement")
+	ErrPermission    = errors.New("database permission denied")
+	ErrNotFound      = errors.New("database record not found")
+	ErrSerialization = errors.New("database serialization failure")
+)
+
+// categoryErrors maps the categories to their sentinel error
+var categoryErrors = map[ErrorCategory]error{
+	ErrorCategoryConnection:    ErrConnection,
+	ErrorCategoryTimeout:       ErrTimeout,
+	ErrorCategoryConstraint:    ErrConstraint,
+	ErrorCategorySyntax:        ErrSyntax,
+	ErrorCategoryPermission:    ErrPermission,
+	ErrorCategoryNotFound:      ErrNotFound,
+	ErrorCategorySerialization: ErrSerialization,
+}
+
+// DatabaseError wraps a database-related error, classified in a category.
+// It matches the sentinel error of its category with errors.Is (e.g. errors.Is(err, ErrConstraint)),
+// and the wrapped driver error can be retrieved with errors.As (e.g. *pq.Error, sqlite3.Error or *mysql.MySQLError).
+type DatabaseError struct {
+	Err      error
+	Category ErrorCategory
+	// Code is the error code of the driver, e.g. the SQLSTATE on postgres, the extended result code on SQLite
+	// or the error number on MySQL, "" if the error does not come from the database server
+	Code string
+	// retryable is whether the error is expected to be temporary
+	retryable bool
+}
+
+// NewDatabaseError returns err wrapped in a classified DatabaseError, or err itself if it is nil or already wraps a DatabaseError
+func NewDatabaseError(err error) error {
+	var dbErr *DatabaseError
+	if err == nil || errors.As(err, &dbErr) {
+		return err
+	}
+	dbErr = &DatabaseError{Err: err, Category: ErrorCategoryOther}
+	dbErr.classify()
+	return dbErr
+}
+
+// Error prints the error message related to database
+func (r *DatabaseError) Error() string {
+	return fmt.Sprintf("database error: %v", r.Err)
+}
+
+// Unwrap returns the wrapped error
+func (r *DatabaseError) Unwrap() error {
+	return r.Err
+}
+
+// Is returns whether target is the sentinel error of the category of the error
+func (r *DatabaseError) Is(target error) bool {
+	sentinel, ok := categoryErrors[r.Category]
+	return ok && target == sentinel
+}
+
+// Retryable returns whether running the statement again may succeed: connection errors, serialization failures and deadlocks,
+// lock timeouts and servers overloaded or shutting down. Unlike IsTransientError, errors which could not be classified are not retryable.
+func (r *DatabaseError) Retryable() bool {
+	return r.retryable
+}
+
+// ErrorCategoryOf returns the category of err, ErrorCategoryOther if it cannot be classified
+func ErrorCategoryOf(err error) ErrorCategory {
+	if err == nil {
+		return ErrorCategoryOther
+	}
+	var dbErr *DatabaseError
+	if !errors.As(NewDatabaseError(err), &dbErr) {
+		return ErrorCategoryOther
+	}
+	return dbErr.Category
+}
+
+// classify sets the category, code and retryability of the error from the error it wraps
+func (r *DatabaseError) classify() {
+	var pqErr *pq.Error
+	var sqliteErr sqlite3.Error
+	var mysqlErr *mysql.MySQLError
+	var netErr net.Error
+	switch {
+	case errors.As(r.Err, &pqErr):
+		r.Code = string(pqErr.Code)
+		r.Category = postgresErrorCategory(pqErr)
+		r.retryable = isTransientPostgresError(pqErr)
+	case errors.As(r.Err, &sqliteErr):
+		r.Code = strconv.Itoa(int(sqliteErr.ExtendedCode))
+		r.Category = sqliteErrorCategory(sqliteErr)
+		r.retryable = isTransientSQLiteError(sqliteErr)
+	case errors.As(r.Err, &mysqlErr):
+		r.Code = strconv.Itoa(int(mysqlErr.Number))
+		r.Category = mysqlErrorCategory(mysqlErr)
+		r.retryable = isTransientMySQLError(mysqlErr)
+	case errors.Is(r.Err, sql.ErrNoRows):
+		r.Category = ErrorCategoryNotFound
+	case errors.Is(r.Err, context.DeadlineExceeded):
+		r.Category = ErrorCategoryTimeout
+	case errors.Is(r.Err, driver.ErrBadConn), errors.Is(r.Err, sql.ErrConnDone), errors.Is(r.Err, mysql.ErrInvalidConn),
+		errors.Is(r.Err, io.ErrUnexpectedEOF), errors.Is(r.Err, ErrNoMatchingHost), errors.As(r.Err, &netErr):
+		r.Category = ErrorCategoryConnection
+		r.retryable = true
+	}
+}
+
+// postgresErrorCategory returns the category of a postgres server error
+func postgresErrorCategory(err *pq.Error) ErrorCategory {
+	switch err.Code {
+	case "40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return ErrorCategorySerialization
+	case "55P03", // lock_not_available
+		"57014": // query_canceled, e.g. statement_timeout
+		return ErrorCategoryTimeout
+	case "42501": // insufficient_privilege
+		return ErrorCategoryPermission
+	case "3D000": // invalid_catalog_name
+		return ErrorCategoryNotFound
+	case "57P01", // admin_shutdown
+		"57P02", // crash_shutdown
+		"57P03": // cannot_connect_now
+		return ErrorCategoryConnection
+	}
+	switch err.Code.Class() {
+	case "08", // connection_exception
+		"53": // insufficient_resources, e.g. too_many_connections
+		return ErrorCategoryConnection
+	case "23": // integrity_constraint_violation
+		return ErrorCategoryConstraint
+	case "42": // syntax_error_or_access_rule_violation
+		return ErrorCategorySyntax
+	case "28": // invalid_authorization_specification
+		return ErrorCategoryPermission
+	}
+	return ErrorCategoryOther
+}
+
+// sqliteErrorCategory returns the category of a sqlite error
+func sqliteErrorCategory(err sqlite3.Error) ErrorCategory {
+	switch err.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked:
+		return ErrorCategorySerialization
+	case sqlite3.ErrInterrupt:
+		return ErrorCategoryTimeout
+	case sqlite3.ErrConstraint:
+		return ErrorCategoryConstraint
+	case sqlite3.ErrPerm, sqlite3.ErrAuth, sqlite3.ErrReadonly:
+		return ErrorCategoryPermission
+	case sqlite3.ErrCantOpen, sqlite3.ErrNotADB, sqlite3.ErrProtocol:
+		return ErrorCategoryConnection
+	case sqlite3.ErrError, sqlite3.ErrRange:
+		// generic SQL errors, e.g. a syntax error, an unknown table or a wrong number of arguments
+		return ErrorCategorySyntax
+	}
+	return ErrorCategoryOther
+}
+
+// mysqlErrorCategory returns the category of a MySQL server error
+func mysqlErrorCategory(err *mysql.MySQLError) ErrorCategory {
+	switch err.Number {
+	case 1040, // ER_CON_COUNT_ERROR
+		1053, // ER_SERVER_SHUTDOWN
+		1203: // ER_TOO_MANY_USER_CONNECTIONS
+		return ErrorCategoryConnection
+	case 1205, // ER_LOCK_WAIT_TIMEOUT
+		1317, // ER_QUERY_INTERRUPTED
+		3024: // ER_QUERY_TIMEOUT
+		return ErrorCategoryTimeout
+	case 1048, // ER_BAD_NULL_ERROR
+		1062, // ER_DUP_ENTRY
+		1169, // ER_DUP_UNIQUE
+		1216, // ER_NO_REFERENCED_ROW
+		1217, // ER_ROW_IS_REFERENCED
+		1364, // ER_NO_DEFAULT_FOR_FIELD
+		1451, // ER_ROW_IS_REFERENCED_2
+		1452, // ER_NO_REFERENCED_ROW_2
+		3819: // ER_CHECK_CONSTRAINT_VIOLATED
+		return ErrorCategoryConstraint
+	case 1054, // ER_BAD_FIELD_ERROR
+		1064, // ER_PARSE_ERROR
+		1146, // ER_NO_SUCH_TABLE
+		1149: // ER_SYNTAX_ERROR
+		return ErrorCategorySyntax
+	case 1044, // ER_DBACCESS_DENIED_ERROR
+		1045, // ER_ACCESS_DENIED_ERROR
+		1142, // ER_TABLEACCESS_DENIED_ERROR
+		1143, // ER_COLUMNACCESS_DENIED_ERROR
+		1227, // ER_SPECIFIC_ACCESS_DENIED_ERROR
+		1370, // ER_PROCACCESS_DENIED_ERROR
+		1698: // ER_ACCESS_DENIED_NO_PASSWORD_ERROR
+		return ErrorCategoryPermission
+	case 1049: // ER_BAD_DB_ERROR
+		return ErrorCategoryNotFound
+	case 1213: // ER_LOCK_DEADLOCK
+		return ErrorCategorySerialization
+	}
+	return ErrorCategoryOther
+}
@@ -0,0 +1,77 @@
+package sdk_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestDatabaseErrorCategories(t *testing.T) {
+	cases := []struct {
+		err       error
+		sentinel  error
+		retryable bool
+	}{
+		{&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, sdk.ErrConnection, true},
+		{&pq.Error{Code: "08006"}, sdk.ErrConnection, true},    // connection_failure
+		{&pq.Error{Code: "57014"}, sdk.ErrTimeout, false},      // query_canceled
+		{&pq.Error{Code: "23505"}, sdk.ErrConstraint, false},   // unique_violation
+		{&pq.Error{Code: "42601"}, sdk.ErrSyntax, false},       // syntax_error
+		{&pq.Error{Code: "42501"}, sdk.ErrPermission, false},   // insufficient_privilege
+		{&pq.Error{Code: "3D000"}, sdk.ErrNotFound, false},     // invalid_catalog_name
+		{&pq.Error{Code: "40001"}, sdk.ErrSerialization, true}, // serialization_failure
+		{sqlite3.Error{Code: sqlite3.ErrBusy}, sdk.ErrSerialization, true},
+		{sqlite3.Error{Code: sqlite3.ErrConstraint}, sdk.ErrConstraint, false},
+		{sqlite3.Error{Code: sqlite3.ErrReadonly}, sdk.ErrPermission, false},
+		{&mysql.MySQLError{Number: 1062}, sdk.ErrConstraint, false},   // ER_DUP_ENTRY
+		{&mysql.MySQLError{Number: 1064}, sdk.ErrSyntax, false},       // ER_PARSE_ERROR
+		{&mysql.MySQLError{Number: 1205}, sdk.ErrTimeout, true},       // ER_LOCK_WAIT_TIMEOUT
+		{&mysql.MySQLError{Number: 1213}, sdk.ErrSerialization, true}, // ER_LOCK_DEADLOCK
+		{sql.ErrNoRows, sdk.ErrNotFound, false},
+		{context.DeadlineExceeded, sdk.ErrTimeout, false},
+	}
+	for _, c := range cases {
+		err := sdk.NewDatabaseError(fmt.Errorf("wrapped: %w", c.err))
+		require.ErrorIs(t, err, c.sentinel, c.err)
+		require.ErrorIs(t, err, c.err)
+		var dbErr *sdk.DatabaseError
+		require.True(t, errors.As(err, &dbErr))
+		require.Equal(t, c.retryable, dbErr.Retryable(), c.err)
+	}
+
+	err := sdk.NewDatabaseError(errors.New("unknown"))
+	require.Equal(t, sdk.ErrorCategoryOther, sdk.ErrorCategoryOf(err))
+	require.Equal(t, err, sdk.NewDatabaseError(err))
+	require.Nil(t, sdk.NewDatabaseError(nil))
+}
+
+func TestDatabaseErrorsOfStatements(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 1)
+	_, err := db.Exec("CREATE UNIQUE INDEX patients_name ON patients(name)")
+	require.NoError(t, err)
+
+	_, err = db.Store(insertQuery+" RETURNING name", name, age, weight, height)
+	require.ErrorIs(t, err, sdk.ErrConstraint)
+	var sqliteErr sqlite3.Error
+	require.True(t, errors.As(err, &sqliteErr))
+	var dbErr *sdk.DatabaseError
+	require.True(t, errors.As(err, &dbErr))
+	require.Equal(t, fmt.Sprint(int(sqlite3.ErrConstraintUnique)), dbErr.Code)
+
+	_, err = db.Retrieve("SELECT * FROM unknown")
+	require.ErrorIs(t, err, sdk.ErrSyntax)
+
+	err = db.Delete("DELETE FROM patients WHERE age = ? RETURNING name", 0)
+	require.ErrorIs(t, err, sdk.ErrNotFound)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	require.Equal(t, sdk.ErrorCategoryNotFound, sdk.ErrorCategoryOf(err))
+}
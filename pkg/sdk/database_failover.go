@@ -0,0 +1,138 @@
+package sdk
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// Target session attributes of PostgresConfig, with the semantics of the libpq parameter target_session_attrs
+const (
+	// TargetSessionAny accepts any server
+	TargetSessionAny = "any"
+	// TargetSessionReadWrite accepts servers accepting writes by default
+	TargetSessionReadWrite = "read-write"
+	// TargetSessionReadOnly accepts servers rejecting writes by default
+	TargetSessionReadOnly = "read-only"
+	// TargetSessionPrimary accepts servers which are not in hot standby mode
+	TargetSessionPrimary = "primary"
+	// TargetSessionStandby accepts servers in hot standby mode
+	TargetSessionStandby = "standby"
+	// TargetSessionPreferStandby accepts servers in hot standby mode, or any server if none is
+	TargetSessionPreferStandby = "prefer-standby"
+)
+
+// ErrNoMatchingHost is returned when connecting to a database whose hosts are all reachable but none matches the target session attributes
+var ErrNoMatchingHost = errors.New("no host matches the target session attributes")
+
+// failoverDatabaseConfig is implemented by DatabaseConfig whose database is served by several hosts
+type failoverDatabaseConfig interface {
+	// failoverHosts should return the address and data source name of each host, in order of preference
+	failoverHosts() []failoverHost
+	// targetSessionAttrs should return the target session attributes of the hosts connected to
+	targetSessionAttrs() string
+}
+
+// replicatedDatabaseConfig is implemented by DatabaseConfig whose reads may be routed to replicas
+type replicatedDatabaseConfig interface {
+	// readReplicaConfig should return the configuration to connect to the read replicas, or nil if reads are not routed
+	readReplicaConfig() DatabaseConfig
+}
+
+// failoverHost is one of the hosts of a failoverDatabaseConfig
+type failoverHost struct {
+	address string
+	dsn     string
+}
+
+// hostDataSourceNames returns the hosts of the database configured by conf, a single one if it does not have several
+func hostDataSourceNames(conf DatabaseConfig) []failoverHost {
+	if fc, ok := conf.(failoverDatabaseConfig); ok {
+		if hosts := fc.failoverHosts(); len(hosts) > 0 {
+			return hosts
+		}
+	}
+	return []failoverHost{{dsn: conf.DataSourceName()}}
+}
+
+// replicaConfig returns the configuration of the read replicas of the database configured by conf, nil if there are none
+func replicaConfig(conf DatabaseConfig) DatabaseConfig {
+	if rc, ok := conf.(replicatedDatabaseConfig); ok {
+		return rc.readReplicaConfig()
+	}
+	return nil
+}
+
+// connectFailover connects to the first of hosts matching targetSessionAttrs, starting with the preferred host
+func (c *dsnConnector) connectFailover(ctx context.Context, hosts []hostConnector, targetSessionAttrs string) (driver.Conn, error) {
+	passes := []string{targetSessionAttrs}
+	if targetSessionAttrs == TargetSessionPreferStandby {
+		passes = []string{TargetSessionStandby, TargetSessionAny}
+	}
+
+	lastErr := ErrNoMatchingHost
+	preferred := int(c.preferred.Load())
+	for _, target := range passes {
+		for i := range hosts {
+			index := (preferred + i) % len(hosts)
+			host := hosts[index]
+			conn, err := host.Connect(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil, err
+				}
+				lastErr = fmt.Errorf("connecting to %v: %w", host.address, err)
+				continue
+			}
+
+			matches, err := postgresSessionMatches(ctx, conn, target)
+			if err != nil || !matches {
+				_ = conn.Close()
+				if err != nil {
+					lastErr = fmt.Errorf("checking session of %v: %w", host.address, err)
+				}
+				continue
+			}
+			if index != preferred {
+				c.preferred.Store(int64(index))
+			}
+			return conn, nil
+		}
+	}
+	return nil, lastErr
+}
+
+// postgresSessionMatches returns whether the session of a postgres connection matches targetSessionAttrs
+func postgresSessionMatches(ctx context.Context, conn driver.Conn, targetSessionAttrs string) (bool, error) {
+	if targetSessionAttrs == "" || targetSessionAttrs == TargetSessionAny {
+		return true, nil
+	}
+	queryer, ok := conn.(driver.QueryerContext)
+	if !ok {
+		return false, fmt.Errorf("the driver connection cannot check target session attributes")
+	}
+	rows, err := queryer.QueryContext(ctx, "SELECT pg_is_in_recovery(), current_setting('transaction_read_only') = 'on'", nil)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	values := make([]driver.Value, 2)
+	if err := rows.Next(values); err != nil {
+		return false, err
+	}
+	inRecovery, _ := values[0].(bool)
+	readOnly, _ := values[1].(bool)
+
+	switch targetSessionAttrs {
+	case TargetSessionReadWrite:
+		return !readOnly, nil
+	case TargetSessionReadOnly:
+		return readOnly, nil
+	case TargetSessionPrimary:
+		return !inRecovery, nil
+	case TargetSessionStandby:
+		return inRecovery, nil
+	}
+	return false, fmt.Errorf("unknown target session attributes %q", targetSessionAttrs)
+}
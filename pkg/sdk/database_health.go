@@ -0,0 +1,202 @@
+package sdk
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// HealthState is the health of a Database managed by a DBManager
+type HealthState string
+
+const (
+	// HealthUnknown is the state of a Database which has not been checked yet
+	HealthUnknown HealthState = "unknown"
+	// HealthHealthy is the state of a Database which answers health-checks in time
+	HealthHealthy HealthState = "healthy"
+	// HealthDegraded is the state of a Database which answers health-checks slowly or recently failed one
+	HealthDegraded HealthState = "degraded"
+	// HealthDown is the state of a Database which failed several health-checks in a row
+	HealthDown HealthState = "down"
+)
+
+const (
+	// DefaultHealthCheckTimeout is the timeout of a health-check when none is configured
+	DefaultHealthCheckTimeout = 5 * time.Second
+	// DefaultHealthDownAfterFailures is the number of consecutive failed health-checks after which a Database is down when none is configured
+	DefaultHealthDownAfterFailures = 3
+	// healthSubscriptionBuffer is the capacity of the channels returned by DBManager.Subscribe
+	healthSubscriptionBuffer = 16
+)
+
+// DatabaseStatus is the health status of a Database managed by a DBManager
+type DatabaseStatus struct {
+	Driver   string
+	Database string
+	State    HealthState
+	// LastCheck and LastSuccess are the times of the last health-check and of the last successful one
+	LastCheck   time.Time
+	LastSuccess time.Time
+	// LastError is the error of the last failed health-check
+	LastError error
+	// Latency is the duration of the last successful health-check
+	Latency             time.Duration
+	ConsecutiveFailures int
+}
+
+// DatabaseStatusChange notifies that the HealthState of a Database changed
+type DatabaseStatusChange struct {
+	Previous HealthState
+	Status   DatabaseStatus
+}
+
+// Status returns the health status of all the databases managed by m, sorted by driver and database name
+func (m *DBManager) Status() []DatabaseStatus {
+	m.Lock()
+	defer m.Unlock()
+	statuses := make([]DatabaseStatus, 0, len(m.databases))
+	for _, mdb := range m.databases {
+		statuses = append(statuses, mdb.healthStatus())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Driver != statuses[j].Driver {
+			return statuses[i].Driver < statuses[j].Driver
+		}
+		return statuses[i].Database < statuses[j].Database
+	})
+	return statuses
+}
+
+// Subscribe returns a channel on which the changes of HealthState of the managed databases are sent, and a function to unsubscribe.
+// Changes are dropped if the channel is not read from fast enough.
+func (m *DBManager) Subscribe() (changes <-chan DatabaseStatusChange, unsubscribe func()) {
+	m.Lock()
+	defer m.Unlock()
+	ch := make(chan DatabaseStatusChange, healthSubscriptionBuffer)
+	m.subscribers[ch] = struct{}{}
+	return ch, func() {
+		m.Lock()
+		defer m.Unlock()
+		if _, ok := m.subscribers[ch]; ok {
+			delete(m.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// CheckHealth pings all the managed databases once, updates their status and notifies the subscribers of any change
+func (m *DBManager) CheckHealth(ctx context.Context) {
+	m.Lock()
+	mdbs := make([]*managedDatabase, 0, len(m.databases))
+	for _, mdb := range m.databases {
+		mdbs = append(mdbs, mdb)
+	}
+	m.Unlock()
+
+	timeout := time.Duration(m.HealthCheckTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+	for _, mdb := range mdbs {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		err := mdb.PingContext(pingCtx)
+		latency := time.Since(start)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		m.updateHealth(mdb, start, latency, err)
+	}
+}
+
+// updateHealth records the result of a health-check of mdb
+func (m *DBManager) updateHealth(mdb *managedDatabase, checkedAt time.Time, latency time.Duration, err error) {
+	m.Lock()
+	defer m.Unlock()
+	if m.databases[mdb.id] != mdb {
+		// the database was closed during the health-check
+		return
+	}
+
+	status := mdb.healthStatus()
+	previous := status.State
+	status.LastCheck = checkedAt
+	if err != nil {
+		downAfter := m.HealthDownAfterFailures
+		if downAfter <= 0 {
+			downAfter = DefaultHealthDownAfterFailures
+		}
+		status.LastError = err
+		status.ConsecutiveFailures++
+		// a database which has not been checked yet is counted as healthy, a single failure right after startup does not make it down
+		if status.ConsecutiveFailures >= downAfter || previous == HealthDown {
+			status.State = HealthDown
+		} else {
+			status.State = HealthDegraded
+		}
+	} else {
+		status.LastSuccess = checkedAt
+		status.Latency = latency
+		status.ConsecutiveFailures = 0
+		degradedLatency := time.Duration(m.HealthDegradedLatencyMilliseconds) * time.Millisecond
+		if degradedLatency > 0 && latency > degradedLatency {
+			status.State = HealthDegraded
+		} else {
+			status.State = HealthHealthy
+		}
+	}
+	mdb.status = status
+
+	if status.State != previous {
+		if status.State == HealthHealthy {
+			m.logger.Infof("database %v is %v", mdb.Name(), status.State)
+		} else {
+			m.logger.Warnf("database %v is %v: %v", mdb.Name(), status.State, status.LastError)
+		}
+		change := DatabaseStatusChange{Previous: previous, Status: status}
+		for ch := range m.subscribers {
+			select {
+			case ch <- change:
+			default:
+				m.logger.Debugf("dropping health change of database %v for a slow subscriber", mdb.Name())
+			}
+		}
+	}
+}
+
+// healthCheckLoop periodically checks the health of the managed databases until the manager is shut down
+func (m *DBManager) healthCheckLoop(interval time.Duration) {
+	defer m.wg.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.CheckHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// healthStatus returns the status of mdb, the manager must be locked
+func (mdb *managedDatabase) healthStatus() DatabaseStatus {
+	status := mdb.status
+	status.Driver = mdb.DriverName()
+	status.Database = mdb.Name()
+	if status.State == "" {
+		status.State = HealthUnknown
+	}
+	return status
+}
@@ -0,0 +1,136 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DatabaseLease is a handle on a Database shared through a DBManager.
+// Each user of the Database (e.g. each DataSource) should acquire its own lease and release it once done,
+// the underlying connection is closed when the last lease is released.
+type DatabaseLease struct {
+	*Database
+	manager  *DBManager
+	mdb      *managedDatabase
+	id       uint64
+	released sync.Once
+}
+
+// LeaseInfo holds diagnostics about an outstanding DatabaseLease
+type LeaseInfo struct {
+	ID         uint64
+	Driver     string
+	Database   string
+	AcquiredAt time.Time
+	// Stack is the stack trace of the goroutine which acquired the lease, if DBManagerConfig.LeaseStackTraces is set
+	Stack string
+}
+
+// String returns a description of the lease and where it was acquired
+func (li LeaseInfo) String() string {
+	description := fmt.Sprintf("lease %v on %v database %v acquired at %v", li.ID, li.Driver, li.Database, li.AcquiredAt.Format(time.RFC3339))
+	if li.Stack == "" {
+		return description
+	}
+	return description + " by:\n" + li.Stack
+}
+
+// Acquire returns a lease on the database from the configuration, creating it if not registered
+func (m *DBManager) Acquire(config DatabaseConfig) (*DatabaseLease, error) {
+	return m.AcquireContext(context.Background(), config)
+}
+
+// AcquireContext returns a lease on the database from the configuration, creating it if not registered.
+// The lease must be released with DatabaseLease.Release once no longer used.
+func (m *DBManager) AcquireContext(ctx context.Context, config DatabaseConfig) (*DatabaseLease, error) {
+	id := ConnectionID(config)
+
+	m.Lock()
+	if mdb, ok := m.databases[id]; ok {
+		defer m.Unlock()
+		return m.newLease(mdb), nil
+	}
+	m.Unlock()
+
+	db, err := m.open(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	return m.newLease(m.register(id, db)), nil
+}
+
+// newLease registers a new lease on mdb, the manager must be locked
+func (m *DBManager) newLease(mdb *managedDatabase) *DatabaseLease {
+	m.lastLeaseID++
+	mdb.touch()
+	lease := &DatabaseLease{Database: mdb.Database, manager: m, mdb: mdb, id: m.lastLeaseID}
+	info := &LeaseInfo{
+		ID:         lease.id,
+		Driver:     mdb.DriverName(),
+		Database:   mdb.Name(),
+		AcquiredAt: time.Now(),
+	}
+	if m.LeaseStackTraces {
+		info.Stack = string(debug.Stack())
+	}
+	mdb.leases[lease.id] = info
+
+	// report leases which are garbage collected without having been released. They are not released,
+	// since the Database of the lease may still be used, so the database stays open until CloseAll.
+	runtime.SetFinalizer(lease, func(l *DatabaseLease) {
+		m.Lock()
+		info, ok := l.mdb.leases[l.id]
+		m.Unlock()
+		if ok {
+			m.logger.Warnf("database lease garbage collected without having been released: %v", info)
+		}
+	})
+	return lease
+}
+
+// Release releases the lease, the underlying database is closed if this was its last lease
+// and it is not otherwise used through DBManager.GetDatabase, or once idle if DBManagerConfig.IdleTimeoutSeconds is set. Releasing a lease more than once has no effect.
+func (l *DatabaseLease) Release() (err error) {
+	l.released.Do(func() {
+		runtime.SetFinalizer(l, nil)
+
+		m := l.manager
+		m.Lock()
+		defer m.Unlock()
+		if _, ok := l.mdb.leases[l.id]; !ok {
+			// the database was closed with DBManager.CloseAll
+			return
+		}
+		delete(l.mdb.leases, l.id)
+		// with an idle timeout, the database is kept open to be reused until it is evicted
+		if len(l.mdb.leases) == 0 && l.mdb.references == 0 && m.IdleTimeoutSeconds <= 0 {
+			err = m.closeDB(l.mdb)
+		}
+	})
+	return
+}
+
+// Close releases the lease, it never closes a database which is still used by other leases
+func (l *DatabaseLease) Close() error {
+	return l.Release()
+}
+
+// Leases returns the diagnostics of all outstanding leases, e.g. to find leases which are never released
+func (m *DBManager) Leases() []LeaseInfo {
+	m.Lock()
+	defer m.Unlock()
+	leases := make([]LeaseInfo, 0)
+	for _, mdb := range m.databases {
+		for _, lease := range mdb.leases {
+			leases = append(leases, *lease)
+		}
+	}
+	return leases
+}
@@ -0,0 +1,156 @@
+package sdk
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQueryLimitExceeded is matched by the errors of statements stopped by a QueryLimits safeguard, see QueryLimitError
+var ErrQueryLimitExceeded = errors.New("query limit exceeded")
+
+// QueryLimit is a safeguard of QueryLimits
+type QueryLimit string
+
+const (
+	// QueryLimitTimeout is the limit on the duration of statements
+	QueryLimitTimeout QueryLimit = "timeout"
+	// QueryLimitRows is the limit on the number of rows returned by a statement
+	QueryLimitRows QueryLimit = "rows"
+	// QueryLimitBytes is the limit on the size of the rows returned by a statement
+	QueryLimitBytes QueryLimit = "bytes"
+)
+
+// LimitedDatabaseConfig is implemented by DatabaseConfig defining their own query limits,
+// which take precedence over the ones of the DBManagerConfig
+type LimitedDatabaseConfig interface {
+	QueryLimitSettings() QueryLimits
+}
+
+// QueryLimits holds the safeguards applied to all the statements sent to a database, zero values disable them.
+// Exceeding a limit stops the statement with a *QueryLimitError, e.g. returned by sql.Rows.Err while reading rows.
+// The connections of databases with limits are wrapped, UnwrapDriverConn returns the connection of the driver given by sql.Conn.Raw.
+type QueryLimits struct {
+	// QueryTimeoutMilliseconds is the maximum duration of statements whose context has no deadline, including the reading of their rows
+	QueryTimeoutMilliseconds int `yaml:"db-query-timeout-ms" default:"0"`
+	// MaxResultRows is the maximum number of rows returned by a statement
+	MaxResultRows int64 `yaml:"db-max-result-rows" default:"0"`
+	// MaxResultBytes is the maximum size of the values of the rows returned by a statement, e.g. the length of strings
+	MaxResultBytes int64 `yaml:"db-max-result-bytes" default:"0"`
+}
+
+// QueryLimitSettings returns the limits themselves, so that QueryLimits embedded in a DatabaseConfig implement LimitedDatabaseConfig
+func (ql QueryLimits) QueryLimitSettings() QueryLimits {
+	return ql
+}
+
+// Merge returns the limits of ql overridden by the non-zero limits of override
+func (ql QueryLimits) Merge(override QueryLimits) QueryLimits {
+	if override.QueryTimeoutMilliseconds != 0 {
+		ql.QueryTimeoutMilliseconds = override.QueryTimeoutMilliseconds
+	}
+	if override.MaxResultRows != 0 {
+		ql.MaxResultRows = override.MaxResultRows
+	}
+	if override.MaxResultBytes != 0 {
+		ql.MaxResultBytes = override.MaxResultBytes
+	}
+	return ql
+}
+
+// enabled returns whether any limit is set
+func (ql QueryLimits) enabled() bool {
+	return ql.QueryTimeoutMilliseconds > 0 || ql.MaxResultRows > 0 || ql.MaxResultBytes > 0
+}
+
+// queryLimitsOf returns the query limits of the database configured by conf
+func queryLimitsOf(conf DatabaseConfig) QueryLimits {
+	if lc, ok := conf.(LimitedDatabaseConfig); ok {
+		return lc.QueryLimitSettings()
+	}
+	return QueryLimits{}
+}
+
+// QueryLimitError is the error of a statement stopped because it exceeded one of its QueryLimits.
+// It matches ErrQueryLimitExceeded with errors.Is, and also context.DeadlineExceeded if the statement timed out.
+type QueryLimitError struct {
+	Limit QueryLimit
+	// Max is the exceeded limit, in milliseconds for timeouts
+	Max int64
+}
+
+// Error returns a description of the exceeded limit
+func (e *QueryLimitError) Error() string {
+	switch e.Limit {
+	case QueryLimitTimeout:
+		return fmt.Sprintf("%v: statement timed out after %v", ErrQueryLimitExceeded, time.Duration(e.Max)*time.Millisecond)
+	case QueryLimitRows:
+		return fmt.Sprintf("%v: statement returned more than %v rows", ErrQueryLimitExceeded, e.Max)
+	}
+	return fmt.Sprintf("%v: statement returned more than %v %v", ErrQueryLimitExceeded, e.Max, e.Limit)
+}
+
+// Is returns whether target is ErrQueryLimitExceeded, or context.DeadlineExceeded for timeouts
+func (e *QueryLimitError) Is(target error) bool {
+	return target == ErrQueryLimitExceeded || (e.Limit == QueryLimitTimeout && target == context.DeadlineExceeded)
+}
+
+// withTimeout returns ctx with the query timeout as deadline if it has none, and whether the timeout was set
+func (ql QueryLimits) withTimeout(ctx context.Context) (context.Context, context.CancelFunc, bool) {
+	if _, ok := ctx.Deadline(); ok || ql.QueryTimeoutMilliseconds <= 0 {
+		return ctx, func() {}, false
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(ql.QueryTimeoutMilliseconds)*time.Millisecond)
+	return ctx, cancel, true
+}
+
+// timeoutError returns a *QueryLimitError instead of err if it was caused by the query timeout of ctx
+func (ql QueryLimits) timeoutError(ctx context.Context, timeoutSet bool, err error) error {
+	if err != nil && timeoutSet && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &QueryLimitError{Limit: QueryLimitTimeout, Max: int64(ql.QueryTimeoutMilliseconds)}
+	}
+	return err
+}
+
+// resultLimiter counts the rows returned by a statement and checks them against its limits
+type resultLimiter struct {
+	limits QueryLimits
+	rows   int64
+	bytes  int64
+}
+
+// add accounts for a row and returns a *QueryLimitError if it exceeds a limit
+func (rl *resultLimiter) add(values []driver.Value) error {
+	rl.rows++
+	if rl.limits.MaxResultRows > 0 && rl.rows > rl.limits.MaxResultRows {
+		return &QueryLimitError{Limit: QueryLimitRows, Max: rl.limits.MaxResultRows}
+	}
+	if rl.limits.MaxResultBytes > 0 {
+		for _, value := range values {
+			rl.bytes += valueSize(value)
+		}
+		if rl.bytes > rl.limits.MaxResultBytes {
+			return &QueryLimitError{Limit: QueryLimitBytes, Max: rl.limits.MaxResultBytes}
+		}
+	}
+	return nil
+}
+
+// valueSize returns the approximate size in bytes of a value returned by a driver
+func valueSize(value driver.Value) int64 {
+	switch v := value.(type) {
+	case nil:
+		return 0
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case bool:
+		return 1
+	case time.Time:
+		return 16
+	}
+	return 8
+}
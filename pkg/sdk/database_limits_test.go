@@ -0,0 +1,138 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestQueryLimitRows(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{QueryLimits: sdk.QueryLimits{MaxResultRows: 1000}}, sdk.SQLiteConfig{QueryLimits: sdk.QueryLimits{MaxResultRows: 5}}, 10)
+
+	rows, err := db.Retrieve(retrieveQuery + " LIMIT 5")
+	require.NoError(t, err)
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, 5, count)
+
+	rows, err = db.Retrieve(retrieveQuery)
+	require.NoError(t, err)
+	count = 0
+	for rows.Next() {
+		count++
+	}
+	require.Equal(t, 5, count)
+	require.ErrorIs(t, rows.Err(), sdk.ErrQueryLimitExceeded)
+	var limitErr *sdk.QueryLimitError
+	require.True(t, errors.As(rows.Err(), &limitErr))
+	require.Equal(t, sdk.QueryLimitRows, limitErr.Limit)
+	require.Equal(t, int64(5), limitErr.Max)
+
+	// the limit also applies to streams and statements run in transactions
+	stream, err := db.Stream(context.Background(), &sdk.StreamOptions{BatchSize: 3}, retrieveQuery)
+	require.NoError(t, err)
+	err = stream.ForEachBatch(func([][]interface{}) error { return nil })
+	require.ErrorIs(t, err, sdk.ErrQueryLimitExceeded)
+
+	err = db.WithTx(context.Background(), nil, func(tx *sdk.Tx) error {
+		rows, err := tx.Retrieve(retrieveQuery)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+		}
+		return rows.Err()
+	})
+	require.ErrorIs(t, err, sdk.ErrQueryLimitExceeded)
+}
+
+func TestQueryLimitBytes(t *testing.T) {
+	// each row is the name (15 bytes), two reals and an int (8 bytes each)
+	db := newTestDatabase(t, sdk.DBManagerConfig{QueryLimits: sdk.QueryLimits{MaxResultRows: 1000}}, sdk.SQLiteConfig{QueryLimits: sdk.QueryLimits{MaxResultBytes: 3 * 39}}, 4)
+
+	rows, err := db.Retrieve(retrieveQuery)
+	require.NoError(t, err)
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	require.Equal(t, 3, count)
+	var limitErr *sdk.QueryLimitError
+	require.True(t, errors.As(rows.Err(), &limitErr))
+	require.Equal(t, sdk.QueryLimitBytes, limitErr.Limit)
+}
+
+func TestQueryLimitTimeout(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{QueryLimits: sdk.QueryLimits{MaxResultRows: 1000}}, sdk.SQLiteConfig{QueryLimits: sdk.QueryLimits{QueryTimeoutMilliseconds: 50}}, 0)
+	slowQuery := `WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n) SELECT count(*) FROM n`
+
+	var count int64
+	err := db.QueryRowContext(context.Background(), slowQuery).Scan(&count)
+	require.ErrorIs(t, err, sdk.ErrQueryLimitExceeded)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	var limitErr *sdk.QueryLimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, sdk.QueryLimitTimeout, limitErr.Limit)
+
+	// the connections remain usable after a timeout
+	require.NoError(t, db.QueryRowContext(context.Background(), "SELECT count(*) FROM patients").Scan(&count))
+	require.Equal(t, int64(0), count)
+	_, err = db.Store(insertQuery+" RETURNING name", name, age, weight, height)
+	require.NoError(t, err)
+
+	// the connection of the driver can be retrieved from the wrapped connection
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Raw(func(driverConn interface{}) error {
+		require.IsType(t, &sqlite3.SQLiteConn{}, sdk.UnwrapDriverConn(driverConn))
+		return nil
+	}))
+}
+
+func TestQueryLimitsIdentity(t *testing.T) {
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{})
+	defer manager.Shutdown()
+	dir := t.TempDir()
+	small := sdk.SQLiteConfig{Directory: dir, Database: "test", QueryLimits: sdk.QueryLimits{MaxResultRows: 2}}
+	large := sdk.SQLiteConfig{Directory: dir, Database: "test", QueryLimits: sdk.QueryLimits{MaxResultRows: 10}}
+	require.NotEqual(t, sdk.ConnectionID(small), sdk.ConnectionID(large))
+
+	smallLease, err := manager.Acquire(small)
+	require.NoError(t, err)
+	defer smallLease.Release()
+	largeLease, err := manager.Acquire(large)
+	require.NoError(t, err)
+	defer largeLease.Release()
+	require.NotSame(t, smallLease.Database, largeLease.Database)
+
+	_, err = largeLease.Exec(createQuery)
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = largeLease.Exec(insertQuery, name, age+i, weight, height)
+		require.NoError(t, err)
+	}
+	count := func(db *sdk.Database) (int, error) {
+		rows, err := db.Retrieve(retrieveQuery)
+		require.NoError(t, err)
+		defer rows.Close()
+		n := 0
+		for rows.Next() {
+			n++
+		}
+		return n, rows.Err()
+	}
+	n, err := count(largeLease.Database)
+	require.NoError(t, err)
+	require.Equal(t, 5, n)
+	_, err = count(smallLease.Database)
+	require.ErrorIs(t, err, sdk.ErrQueryLimitExceeded)
+}
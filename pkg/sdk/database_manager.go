@@ -1,108 +1,372 @@
 package sdk
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"hash/crc64"
 	"sync"
 	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk/credentials"
 )
 
-// DBManager manages live database connections
+// DBManager manages live database connections.
+// Each call to GetDatabase or NewDatabase takes a reference on the returned database, which is kept open until
+// every reference has been given back with Close (or until CloseAll is called), so that data sources sharing a database
+// do not close it under each other. Databases obtained with Acquire are closed once their last DatabaseLease is released.
 type DBManager struct {
 	DBManagerConfig
 	sync.Mutex
-	// maps parameters checksum to database interface
-	databases map[int64]*Database
+	// maps connection identities (see ConnectionID) to database interface
+	databases map[string]*managedDatabase
+	logger    logrus.FieldLogger
+	// lastLeaseID is the ID of the last DatabaseLease handed out
+	lastLeaseID uint64
+	// credentialsProvider resolves the credentials of CredentialedDatabaseConfig
+	credentialsProvider credentials.Provider
+	// subscribers are the channels notified of database health changes
+	subscribers map[chan DatabaseStatusChange]struct{}
+	// metrics records the metrics of the databases, nil if disabled
+	metrics *dbMetrics
+
+	// stop is closed to stop the background tasks of the manager
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// managedDatabase is a Database held by the DBManager along with its users
+type managedDatabase struct {
+	*Database
+	id string
+	// references is the number of GetDatabase and NewDatabase calls on the Database not yet matched by a call to Close,
+	// the Database is kept open while it is positive
+	references int
+	// leases maps the IDs of the outstanding leases on the Database to their diagnostics
+	leases map[uint64]*LeaseInfo
+	// status is the health status of the Database
+	status DatabaseStatus
 }
 
 // DBManagerConfig regroups parameters for connection to all databases
 type DBManagerConfig struct {
 	MaxConnectionAttempts              int `yaml:"db-max-conn-attempts" default:"3"`
 	SleepingTimeBetweenAttemptsSeconds int `yaml:"db-sleeping-time" default:"4"`
+
+	// RetryPolicy is the policy used between connection attempts: "constant" (waits SleepingTimeBetweenAttemptsSeconds)
+	// or "exponential" (configured by the Retry* fields below), both give up after MaxConnectionAttempts retries
+	RetryPolicy                      string  `yaml:"db-retry-policy" default:"constant"`
+	RetryInitialIntervalMilliseconds int     `yaml:"db-retry-initial-interval-ms" default:"500"`
+	RetryMaxIntervalSeconds          int     `yaml:"db-retry-max-interval" default:"30"`
+	RetryMultiplier                  float64 `yaml:"db-retry-multiplier" default:"2"`
+	RetryJitter                      float64 `yaml:"db-retry-jitter" default:"0.2"`
+	RetryMaxElapsedTimeSeconds       int     `yaml:"db-retry-max-elapsed-time" default:"0"`
+
+	// PoolConfig holds the default connection pool settings, overridden by the ones of each DatabaseConfig
+	PoolConfig `yaml:",inline"`
+	// QueryLimits holds the default query timeout and result size limits, overridden by the ones of each DatabaseConfig
+	QueryLimits `yaml:",inline"`
+	// IdleTimeoutSeconds is the time after which an unused database obtained with Acquire without outstanding leases is closed,
+	// it is transparently reopened on the next Acquire. 0 disables the eviction of idle databases, which are then closed
+	// as soon as their last lease is released.
+	IdleTimeoutSeconds int `yaml:"db-idle-timeout" default:"0"`
+	// IdleCheckIntervalSeconds is the interval between two checks for idle databases, half of IdleTimeoutSeconds if 0
+	IdleCheckIntervalSeconds int `yaml:"db-idle-check-interval" default:"0"`
+	// LeaseStackTraces records the stack trace of the goroutine acquiring each DatabaseLease in its LeaseInfo, to find leaked leases.
+	// It is a debugging option, capturing a stack trace on every Acquire being costly.
+	LeaseStackTraces bool `yaml:"db-lease-stack-traces" default:"false"`
+
+	// HealthCheckIntervalSeconds is the interval between two health-checks of all databases, 0 disables background health-checks
+	HealthCheckIntervalSeconds int `yaml:"db-health-check-interval" default:"0"`
+	HealthCheckTimeoutSeconds  int `yaml:"db-health-check-timeout" default:"5"`
+	// HealthDegradedLatencyMilliseconds is the health-check latency above which a database is degraded, 0 disables the latency check
+	HealthDegradedLatencyMilliseconds int `yaml:"db-health-degraded-latency-ms" default:"0"`
+	// HealthDownAfterFailures is the number of consecutive failed health-checks after which a database is down
+	HealthDownAfterFailures int `yaml:"db-health-down-after-failures" default:"3"`
+
+	// StatementCacheSize is the number of prepared statements cached by each database (see Database.EnableStatementCache), 0 disables the cache
+	StatementCacheSize int `yaml:"db-statement-cache-size" default:"0"`
+
+	// Tracing makes the operations of each database create OpenTelemetry spans (see Database.EnableTracing)
+	Tracing bool `yaml:"db-tracing" default:"false"`
+	// Metrics makes the manager record OpenTelemetry metrics with the global meter provider: the statistics of the connection pools,
+	// the duration and errors of the database operations and the connection retries, labelled by driver and redacted database name
+	Metrics bool `yaml:"db-metrics" default:"false"`
+}
+
+// NewRetryPolicy returns the RetryPolicy described by the configuration
+func (conf DBManagerConfig) NewRetryPolicy() RetryPolicy {
+	if conf.RetryPolicy == ExponentialRetryPolicy {
+		return ExponentialBackOff{
+			InitialInterval:     time.Duration(conf.RetryInitialIntervalMilliseconds) * time.Millisecond,
+			MaxInterval:         time.Duration(conf.RetryMaxIntervalSeconds) * time.Second,
+			Multiplier:          conf.RetryMultiplier,
+			RandomizationFactor: conf.RetryJitter,
+			MaxElapsedTime:      time.Duration(conf.RetryMaxElapsedTimeSeconds) * time.Second,
+			MaxRetries:          conf.MaxConnectionAttempts,
+		}
+	}
+	return ConstantBackOff{
+		Interval:   time.Duration(conf.SleepingTimeBetweenAttemptsSeconds) * time.Second,
+		MaxRetries: conf.MaxConnectionAttempts,
+	}
 }
 
 // NewDBManager creates a new manager with an empty map of databases
 func NewDBManager(config DBManagerConfig) *DBManager {
 	m := new(DBManager)
 	m.DBManagerConfig = config
-	m.databases = make(map[int64]*Database)
+	m.databases = make(map[string]*managedDatabase)
+	m.logger = logrus.New().WithField("component", "db-manager")
+	m.subscribers = make(map[chan DatabaseStatusChange]struct{})
+	m.stop = make(chan struct{})
+	if config.Metrics {
+		metrics, err := newDBMetrics(m)
+		if err != nil {
+			m.logger.Warnf("creating database metrics: %v", err)
+		}
+		m.metrics = metrics
+	}
+	if config.IdleTimeoutSeconds > 0 {
+		idleTimeout := time.Duration(config.IdleTimeoutSeconds) * time.Second
+		interval := time.Duration(config.IdleCheckIntervalSeconds) * time.Second
+		if interval <= 0 {
+			interval = idleTimeout / 2
+		}
+		m.wg.Add(1)
+		go m.evictIdleLoop(interval, idleTimeout)
+	}
+	if config.HealthCheckIntervalSeconds > 0 {
+		m.wg.Add(1)
+		go m.healthCheckLoop(time.Duration(config.HealthCheckIntervalSeconds) * time.Second)
+	}
 	return m
 }
 
-// NewDatabase creates a new database given a generic configuration
+// Shutdown stops the background tasks of the manager, closes the health subscriptions and all db connections
+func (m *DBManager) Shutdown() error {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	m.wg.Wait()
+
+	if m.metrics != nil {
+		if err := m.metrics.registration.Unregister(); err != nil {
+			m.logger.Warnf("unregistering database metrics: %v", err)
+		}
+	}
+	m.Lock()
+	for ch := range m.subscribers {
+		delete(m.subscribers, ch)
+		close(ch)
+	}
+	m.Unlock()
+	return m.CloseAll()
+}
+
+// NewDatabase creates a new database given a generic configuration, see NewDatabaseContext
 func (m *DBManager) NewDatabase(config DatabaseConfig) (db *Database, err error) {
-	conn, err := NewConnection(config)
+	return m.NewDatabaseContext(context.Background(), config)
+}
+
+// NewDatabaseContext creates a new database given a generic configuration, waiting for it to be ready until ctx is done.
+// If a database with the same identity (see ConnectionID) is already registered, it is returned instead.
+// Each call takes a reference on the database, which the caller must give back with Close once it no longer uses it.
+func (m *DBManager) NewDatabaseContext(ctx context.Context, config DatabaseConfig) (db *Database, err error) {
+	id := ConnectionID(config)
+	m.Lock()
+	if mdb, ok := m.databases[id]; ok {
+		defer m.Unlock()
+		mdb.references++
+		mdb.touch()
+		return mdb.Database, nil
+	}
+	m.Unlock()
+
+	db, err = m.open(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	m.Lock()
+	defer m.Unlock()
+	mdb := m.register(id, db)
+	mdb.references++
+	return mdb.Database, nil
+}
+
+// open connects to the database described by config without registering it,
+// resolving its credentials first if it is a CredentialedDatabaseConfig
+func (m *DBManager) open(ctx context.Context, config DatabaseConfig) (db *Database, err error) {
+	resolved, err := m.resolveConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	connector, err := newDSNConnector(resolved)
 	if err != nil {
 		return nil, fmt.Errorf("creating database connection: %w", err)
 	}
-	db, err = NewDatabase(config, conn, m.MaxConnectionAttempts, time.Duration(m.SleepingTimeBetweenAttemptsSeconds)*time.Second)
+	conn := sql.OpenDB(connector)
+	pool := m.PoolConfig
+	if pooled, ok := config.(PooledDatabaseConfig); ok {
+		pool = pool.Merge(pooled.PoolSettings())
+	}
+	pool.Apply(conn)
+	limits := m.QueryLimits.Merge(queryLimitsOf(config))
+	connector.limits = limits
+
+	var replica *sql.DB
+	var replicaConnector *dsnConnector
+	if replicaConf := replicaConfig(resolved); replicaConf != nil {
+		replicaConnector, err = newDSNConnector(replicaConf)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("creating read replica connection: %w", err)
+		}
+		replicaConnector.limits = limits
+		replica = sql.OpenDB(replicaConnector)
+		pool.Apply(replica)
+	}
+
+	db, err = NewDatabaseWithRetryPolicy(ctx, config, conn, m.NewRetryPolicy())
 	if err != nil {
+		if replica != nil {
+			_ = replica.Close()
+		}
 		return nil, err
 	}
-	m.addDB(Checksum(config), db)
+	db.Replica = replica
+	db.limits = limits
+	db.refreshCredentials = m.credentialsRefresher(config, connector, replicaConnector)
+	if m.StatementCacheSize > 0 {
+		db.EnableStatementCache(m.StatementCacheSize)
+	}
+	db.EnableTracing(m.Tracing)
+	db.metrics = m.metrics
+	db.metricName = metricDatabaseName(resolved)
 	return db, nil
 }
 
-// GetDatabase return the database instance from the configuration, or creates a new one if not registered
+// GetDatabase return the database instance from the configuration, or creates a new one if not registered, see NewDatabaseContext
 func (m *DBManager) GetDatabase(config DatabaseConfig) (db *Database, err error) {
-	var ok bool
-	db, ok = m.getDB(Checksum(config))
-	if !ok {
-		return m.NewDatabase(config)
-	}
-	return db, nil
+	return m.GetDatabaseContext(context.Background(), config)
 }
 
-// CloseAll attempts to close all db connections, concatenates any errors (locks the manager)
+// GetDatabaseContext return the database instance from the configuration, or creates a new one if not registered, see NewDatabaseContext
+func (m *DBManager) GetDatabaseContext(ctx context.Context, config DatabaseConfig) (db *Database, err error) {
+	return m.NewDatabaseContext(ctx, config)
+}
+
+// CloseAll attempts to close all db connections, concatenates any errors (locks the manager).
+// Leases that were not released are reported as leaked and become invalid.
 func (m *DBManager) CloseAll() error {
 	m.Lock()
 	defer m.Unlock()
 	errs := make([]error, 0)
-	for cs, db := range m.databases {
-		err := db.Close()
+	for id, mdb := range m.databases {
+		for _, lease := range mdb.leases {
+			m.logger.Warnf("closing database %v with a leaked lease: %v", mdb.Name(), lease)
+		}
+		err := mdb.Close()
 		if err != nil {
 			errs = append(errs, err)
 		} else {
-			delete(m.databases, cs)
+			delete(m.databases, id)
 		}
 	}
 	return WrapErrors("closing databases:", errs)
 }
 
-// Close closes the db given parameters
+// Close gives back a reference taken on the db given parameters by GetDatabase or NewDatabase.
+// The database is only closed once all its references have been given back and its leases have been released.
 func (m *DBManager) Close(config DatabaseConfig) error {
-	db, ok := m.getDB(Checksum(config))
+	m.Lock()
+	defer m.Unlock()
+	mdb, ok := m.databases[ConnectionID(config)]
 	if !ok {
 		return nil
 	}
-	err := db.Close()
+	if mdb.references > 0 {
+		mdb.references--
+	}
+	if mdb.references > 0 {
+		m.logger.Debugf("database %v is still used by %v other reference(s)", mdb.Name(), mdb.references)
+		return nil
+	}
+	if len(mdb.leases) > 0 {
+		m.logger.Infof("database %v still has %v outstanding lease(s), it will be closed once they are released", mdb.Name(), len(mdb.leases))
+		return nil
+	}
+	return m.closeDB(mdb)
+}
+
+// register registers db under id and returns it, the manager must be locked. If another goroutine registered
+// a database with the same identity in the meantime, db is closed and the registered database is returned instead.
+func (m *DBManager) register(id string, db *Database) *managedDatabase {
+	if mdb, ok := m.databases[id]; ok {
+		if err := db.Close(); err != nil {
+			m.logger.Warnf("closing duplicate connection to %v: %v", db.Name(), err)
+		}
+		return mdb
+	}
+	mdb := &managedDatabase{Database: db, id: id, leases: make(map[uint64]*LeaseInfo)}
+	m.databases[id] = mdb
+	return mdb
+}
+
+// closeDB closes mdb and removes it from the manager, the manager must be locked
+func (m *DBManager) closeDB(mdb *managedDatabase) error {
+	err := mdb.Close()
 	if err != nil {
 		return err
 	}
-	m.deleteDB(Checksum(config))
+	if m.databases[mdb.id] == mdb {
+		delete(m.databases, mdb.id)
+	}
 	return nil
 }
 
-func (m *DBManager) addDB(cs int64, db *Database) {
-	m.Lock()
-	defer m.Unlock()
-	m.databases[cs] = db
-}
-
-func (m *DBManager) getDB(cs int64) (db *Database, ok bool) {
+// EvictIdle closes the databases which have not been used for at least idleTimeout,
+// have no outstanding lease and no connection in use. It returns the number of closed databases.
+// Databases obtained with GetDatabase or NewDatabase are never evicted since their users keep using them,
+// PoolConfig.ConnMaxIdleTimeSeconds closes their idle connections instead.
+func (m *DBManager) EvictIdle(idleTimeout time.Duration) (evicted int) {
 	m.Lock()
 	defer m.Unlock()
-	db, ok = m.databases[cs]
+	for _, mdb := range m.databases {
+		idleTime := time.Since(mdb.LastUsed())
+		if mdb.references > 0 || len(mdb.leases) > 0 || mdb.Stats().InUse > 0 || idleTime < idleTimeout {
+			continue
+		}
+		m.logger.Infof("closing database %v unused for %v", mdb.Name(), idleTime.Round(time.Second))
+		if err := m.closeDB(mdb); err != nil {
+			m.logger.Warnf("closing idle database %v: %v", mdb.Name(), err)
+			continue
+		}
+		evicted++
+	}
 	return
 }
 
-func (m *DBManager) deleteDB(cs int64) {
-	m.Lock()
-	defer m.Unlock()
-	delete(m.databases, cs)
+// evictIdleLoop periodically closes idle databases until the manager is shut down
+func (m *DBManager) evictIdleLoop(interval, idleTimeout time.Duration) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.EvictIdle(idleTimeout)
+		}
+	}
 }
 
-// Checksum returns the checksum of a connection configuration which is created using the datasource name and the driver name
+// Checksum returns the checksum of a connection configuration which is created using the datasource name and the driver name.
+//
+// Deprecated: the checksum depends on the secrets of the configuration and may collide, use ConnectionID instead.
 func Checksum(config DatabaseConfig) int64 {
 	uniqueString := config.DriverName() + "." + config.DataSourceName()
 	return int64(crc64.Checksum([]byte(uniqueString), crc64.MakeTable(crc64.ECMA)))
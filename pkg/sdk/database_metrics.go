@@ -0,0 +1,164 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MeterName is the name of the OpenTelemetry meter recording the metrics of the databases of a DBManager
+const MeterName = "github.com/tuneinsight/sdk-datasource/pkg/sdk"
+
+// Attributes of the database metrics
+const (
+	// metricDriverKey is the driver of a database and metricNameKey its name with any secret redacted, see metricDatabaseName
+	metricDriverKey = attribute.Key("db.driver")
+	metricNameKey   = attribute.Key("db.name")
+	// metricPoolKey is the connection pool of a database, "primary" or "replica"
+	metricPoolKey = attribute.Key("db.pool")
+	// metricOperationKey is the Database operation, e.g. Store or Retrieve
+	metricOperationKey = attribute.Key("db.operation")
+)
+
+// dbMetrics are the instruments of the metrics of the databases of a DBManager
+type dbMetrics struct {
+	duration instrument.Float64Histogram
+	errors   instrument.Int64Counter
+	retries  instrument.Int64Counter
+	// registration is the registration of the callback observing the statistics of the connection pools
+	registration metric.Registration
+}
+
+// newDBMetrics creates the instruments of the metrics of the databases of m with the global OpenTelemetry meter provider
+func newDBMetrics(m *DBManager) (metrics *dbMetrics, err error) {
+	meter := global.Meter(MeterName)
+	metrics = new(dbMetrics)
+	metrics.duration, err = meter.Float64Histogram("db.client.operation.duration",
+		instrument.WithUnit(string(unit.Milliseconds)), instrument.WithDescription("Duration of the database operations"))
+	if err != nil {
+		return nil, err
+	}
+	metrics.errors, err = meter.Int64Counter("db.client.operation.errors",
+		instrument.WithDescription("Number of failed database operations, by error category"))
+	if err != nil {
+		return nil, err
+	}
+	metrics.retries, err = meter.Int64Counter("db.client.connection.retries",
+		instrument.WithDescription("Number of failed connection attempts retried by WaitReady"))
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := meter.Int64ObservableGauge("db.client.connections.open",
+		instrument.WithDescription("Number of established connections, in use or idle"))
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := meter.Int64ObservableGauge("db.client.connections.in_use",
+		instrument.WithDescription("Number of connections in use"))
+	if err != nil {
+		return nil, err
+	}
+	idle, err := meter.Int64ObservableGauge("db.client.connections.idle",
+		instrument.WithDescription("Number of idle connections"))
+	if err != nil {
+		return nil, err
+	}
+	waitCount, err := meter.Int64ObservableCounter("db.client.connections.wait_count",
+		instrument.WithDescription("Total number of connections waited for"))
+	if err != nil {
+		return nil, err
+	}
+	waitDuration, err := meter.Float64ObservableCounter("db.client.connections.wait_duration",
+		instrument.WithUnit(string(unit.Milliseconds)), instrument.WithDescription("Total time blocked waiting for a new connection"))
+	if err != nil {
+		return nil, err
+	}
+
+	observe := func(o metric.Observer, stats sql.DBStats, attributes []attribute.KeyValue) {
+		o.ObserveInt64(open, int64(stats.OpenConnections), attributes...)
+		o.ObserveInt64(inUse, int64(stats.InUse), attributes...)
+		o.ObserveInt64(idle, int64(stats.Idle), attributes...)
+		o.ObserveInt64(waitCount, stats.WaitCount, attributes...)
+		o.ObserveFloat64(waitDuration, float64(stats.WaitDuration)/float64(time.Millisecond), attributes...)
+	}
+	metrics.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		m.Lock()
+		defer m.Unlock()
+		for _, mdb := range m.databases {
+			attributes := append(mdb.metricAttributes(), metricPoolKey.String("primary"))
+			observe(o, mdb.DB.Stats(), attributes)
+			if mdb.Replica != nil {
+				attributes = append(mdb.metricAttributes(), metricPoolKey.String("replica"))
+				observe(o, mdb.Replica.Stats(), attributes)
+			}
+		}
+		return nil
+	}, open, inUse, idle, waitCount, waitDuration)
+	if err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// metricAttributes returns the attributes identifying the Database in metrics
+func (db *Database) metricAttributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		metricDriverKey.String(db.DriverName()),
+		metricNameKey.String(db.metricName),
+	}
+}
+
+// metricDatabaseName returns the name of the database configured by config in metrics: its name, or its redacted data source name
+// if it has none (e.g. postgres connection strings of credentials), with the secrets of any connection string used as name redacted
+func metricDatabaseName(config DatabaseConfig) string {
+	if name := config.Name(); name != "" {
+		return redactDataSourceName(name)
+	}
+	return RedactedDataSourceName(config)
+}
+
+// operation is an ongoing operation of a Database, which is traced and measured if enabled
+type operation struct {
+	db    *Database
+	name  string
+	span  trace.Span
+	start time.Time
+	// nested operations are only traced, they are measured as part of the operation calling them
+	nested bool
+}
+
+// startOperation starts an operation running statement, if any, see startSpan
+func (db *Database) startOperation(ctx context.Context, name string, statement string) (context.Context, operation) {
+	ctx, span := db.startSpan(ctx, name, statement)
+	return ctx, operation{db: db, name: name, span: span, start: time.Now()}
+}
+
+// retry records a failed attempt of the operation which is retried after backOff
+func (op operation) retry(attempt int, backOff time.Duration, err error) {
+	recordRetry(op.span, attempt, backOff, err)
+	if op.db.metrics != nil {
+		op.db.metrics.retries.Add(context.Background(), 1, op.db.metricAttributes()...)
+	}
+}
+
+// end ends the operation which returned err.
+// Its metrics are recorded without its context, which may be done and would discard them, e.g. after a timeout.
+func (op operation) end(err error) {
+	endSpan(op.span, err)
+	if op.db.metrics == nil || op.nested {
+		return
+	}
+	attributes := append(op.db.metricAttributes(), metricOperationKey.String(op.name))
+	op.db.metrics.duration.Record(context.Background(), float64(time.Since(op.start))/float64(time.Millisecond), attributes...)
+	if err != nil {
+		op.db.metrics.errors.Add(context.Background(), 1, append(attributes, errorCategoryKey.String(string(ErrorCategoryOf(err))))...)
+	}
+}
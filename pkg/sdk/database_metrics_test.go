@@ -0,0 +1,101 @@
+package sdk_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/global"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collectMetrics returns the metrics recorded by reader, indexed by name
+func collectMetrics(t *testing.T, reader sdkmetric.Reader) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := make(map[string]metricdata.Aggregation)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+func TestDatabaseMetrics(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	previous := global.MeterProvider()
+	global.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+	t.Cleanup(func() { global.SetMeterProvider(previous) })
+
+	db := newTestDatabase(t, sdk.DBManagerConfig{Metrics: true}, sdk.SQLiteConfig{}, 0)
+
+	_, err := db.Store(insertQuery+" RETURNING name", name, age, weight, height)
+	require.NoError(t, err)
+	rows, err := db.Retrieve(retrieveQuery)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+	err = db.Delete("DELETE FROM patients WHERE age = ? RETURNING name", 0)
+	require.Error(t, err)
+	require.NoError(t, db.WaitReady())
+
+	metrics := collectMetrics(t, reader)
+	databaseAttributes := []attribute.KeyValue{attribute.String("db.driver", "sqlite3"), attribute.String("db.name", "test")}
+
+	open := metrics["db.client.connections.open"].(metricdata.Gauge[int64])
+	require.Len(t, open.DataPoints, 1)
+	require.Equal(t, attribute.NewSet(append(databaseAttributes, attribute.String("db.pool", "primary"))...), open.DataPoints[0].Attributes)
+	require.GreaterOrEqual(t, open.DataPoints[0].Value, int64(1))
+	for _, name := range []string{"db.client.connections.in_use", "db.client.connections.idle", "db.client.connections.wait_count", "db.client.connections.wait_duration"} {
+		require.Contains(t, metrics, name)
+	}
+
+	durations := make(map[string]uint64)
+	for _, point := range metrics["db.client.operation.duration"].(metricdata.Histogram).DataPoints {
+		operation, _ := point.Attributes.Value("db.operation")
+		durations[operation.AsString()] = point.Count
+	}
+	// the health-checks of the operations are part of their duration, only the one called directly is measured on its own
+	require.Equal(t, map[string]uint64{"WaitReady": 1, "Store": 1, "Retrieve": 1, "Delete": 1}, durations)
+
+	errors := metrics["db.client.operation.errors"].(metricdata.Sum[int64]).DataPoints
+	require.Len(t, errors, 1)
+	require.Equal(t, int64(1), errors[0].Value)
+	category, _ := errors[0].Attributes.Value("db.error.category")
+	require.Equal(t, string(sdk.ErrorCategoryNotFound), category.AsString())
+
+	// failed connection attempts retried by WaitReady are counted
+	require.NoError(t, db.DB.Close())
+	db.RetryPolicy = sdk.ConstantBackOff{Interval: time.Millisecond, MaxRetries: 2}
+	require.Error(t, db.WaitReady())
+	retries := collectMetrics(t, reader)["db.client.connection.retries"].(metricdata.Sum[int64]).DataPoints
+	require.Len(t, retries, 1)
+	require.Equal(t, int64(2), retries[0].Value)
+	require.Equal(t, attribute.NewSet(databaseAttributes...), retries[0].Attributes)
+
+	// the secrets of database names which are connection strings are redacted
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{Metrics: true})
+	defer manager.Shutdown()
+	_, err = manager.NewDatabase(namedSQLiteConfig{SQLiteConfig: sdk.SQLiteConfig{Directory: t.TempDir(), Database: "named"}, name: "dbname=named password=secret"})
+	require.NoError(t, err)
+	names := make([]string, 0)
+	for _, point := range collectMetrics(t, reader)["db.client.connections.open"].(metricdata.Gauge[int64]).DataPoints {
+		dbName, _ := point.Attributes.Value("db.name")
+		names = append(names, dbName.AsString())
+	}
+	require.Contains(t, names, "dbname=named password=xxxxx")
+}
+
+// namedSQLiteConfig is a SQLite configuration with another name than its database file
+type namedSQLiteConfig struct {
+	sdk.SQLiteConfig
+	name string
+}
+
+func (conf namedSQLiteConfig) Name() string {
+	return conf.name
+}
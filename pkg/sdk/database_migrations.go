@@ -0,0 +1,441 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultMigrationLockTimeout is the maximum time a Migrator waits for another one to release the migrations lock
+const DefaultMigrationLockTimeout = time.Minute
+
+var (
+	// migrationOwnerRegex restricts migration owners to valid unquoted SQL identifiers
+	migrationOwnerRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// migrationFileRegex matches migration files named <version>_<name>.(up|down).sql
+	migrationFileRegex = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+)
+
+// Migration is a versioned change of the schema of the tables owned by a plugin.
+// Its changes are given either as SQL scripts (UpSQL, DownSQL) or as functions (Up, Down), which run in a transaction.
+// Note that MySQL implicitly commits schema changes, so a failed MySQL migration may be partially applied.
+type Migration struct {
+	// Version orders the migrations, it must be positive and unique
+	Version int64
+	Name    string
+
+	UpSQL   string
+	DownSQL string
+	Up      func(ctx context.Context, tx Executor) error
+	Down    func(ctx context.Context, tx Executor) error
+}
+
+// MigrationsFromFS loads the migrations from the SQL scripts in directory dir of fsys (e.g. an embed.FS).
+// Scripts must be named <version>_<name>.up.sql and <version>_<name>.down.sql, the down script being optional.
+func MigrationsFromFS(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations directory %v: %w", dir, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFileRegex.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing version of migration %v: %w", entry.Name(), err)
+		}
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %v: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %v and %v have the same version", migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.UpSQL = string(script)
+		} else {
+			migration.DownSQL = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpSQL == "" {
+			return nil, fmt.Errorf("migration %v_%v has no up script", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies the migrations of a plugin to a Database.
+// The applied versions are recorded in the table <owner>_schema_migrations, and concurrent Migrators
+// on the same database (e.g. several TI Note replicas) are serialized with a lock.
+type Migrator struct {
+	db         *Database
+	owner      string
+	table      string
+	migrations []Migration
+	// LockTimeout is the maximum time to wait for another Migrator to release the lock, DefaultMigrationLockTimeout if 0
+	LockTimeout time.Duration
+}
+
+// NewMigrator returns a Migrator applying migrations to db on behalf of owner, which must be a valid SQL identifier (e.g. the plugin name)
+func NewMigrator(db *Database, owner string, migrations ...Migration) (*Migrator, error) {
+	if !migrationOwnerRegex.MatchString(owner) {
+		return nil, fmt.Errorf("invalid migrations owner %q: must only contain letters, digits and underscores", owner)
+	}
+
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, migration := range sorted {
+		if migration.Version <= 0 {
+			return nil, fmt.Errorf("migration %v has a non-positive version %v", migration.Name, migration.Version)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("migrations %v and %v have the same version %v", sorted[i-1].Name, migration.Name, migration.Version)
+		}
+		if migration.UpSQL == "" && migration.Up == nil {
+			return nil, fmt.Errorf("migration %v_%v has no up change", migration.Version, migration.Name)
+		}
+	}
+
+	return &Migrator{
+		db:         db,
+		owner:      owner,
+		table:      owner + "_schema_migrations",
+		migrations: sorted,
+	}, nil
+}
+
+// Version returns the latest applied migration version, 0 if none was applied
+func (m *Migrator) Version(ctx context.Context) (version int64, err error) {
+	err = m.db.WaitReadyContext(ctx)
+	if err != nil {
+		return
+	}
+	err = m.ensureTable(ctx, m.db.DB)
+	if err != nil {
+		return
+	}
+	return m.currentVersion(ctx, m.db.DB)
+}
+
+// Up applies all the pending migrations and returns the number of applied migrations
+func (m *Migrator) Up(ctx context.Context) (applied int, err error) {
+	if len(m.migrations) == 0 {
+		return 0, nil
+	}
+	return m.MigrateTo(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down reverts the latest applied migration
+func (m *Migrator) Down(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil || version == 0 {
+		return err
+	}
+	previous := int64(0)
+	for _, migration := range m.migrations {
+		if migration.Version < version {
+			previous = migration.Version
+		}
+	}
+	_, err = m.MigrateTo(ctx, previous)
+	return err
+}
+
+// MigrateTo applies or reverts migrations until version is the latest applied one, 0 reverting all migrations.
+// Each migration runs in its own transaction, it returns the number of applied or reverted migrations.
+func (m *Migrator) MigrateTo(ctx context.Context, version int64) (steps int, err error) {
+	err = m.db.WaitReadyContext(ctx)
+	if err != nil {
+		return
+	}
+
+	// all the steps run in the same session, which holds the lock
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("opening migrations session: %w", err)
+	}
+	defer conn.Close()
+
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = unlockErr
+		}
+	}()
+
+	err = m.ensureTable(ctx, conn)
+	if err != nil {
+		return
+	}
+
+	for {
+		var done bool
+		done, err = m.step(ctx, conn, version)
+		if err != nil || done {
+			return
+		}
+		steps++
+	}
+}
+
+// step applies or reverts a single migration towards version in a transaction, done is true if there is nothing left to do
+func (m *Migrator) step(ctx context.Context, conn *sql.Conn, version int64) (done bool, err error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("beginning migration transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if m.db.DriverName() == "sqlite3" {
+		// sqlite has no advisory locks: take the database write lock before reading the current version
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+m.table+" WHERE 1 = 0")
+		if err != nil {
+			return false, fmt.Errorf("locking migrations table: %w", err)
+		}
+	}
+
+	current, err := m.currentVersion(ctx, tx)
+	if err != nil {
+		return
+	}
+
+	var migration Migration
+	var up bool
+	switch {
+	case current < version:
+		migration, up = m.next(current), true
+		if migration.Version == 0 || migration.Version > version {
+			return true, tx.Rollback()
+		}
+	case current > version:
+		var ok bool
+		if migration, ok = m.find(current); !ok {
+			return false, fmt.Errorf("applied migration %v is unknown to the migrator", current)
+		}
+	default:
+		return true, tx.Rollback()
+	}
+
+	err = m.run(ctx, tx, migration, up)
+	if err != nil {
+		return
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %v (version, name, applied_at) VALUES (%v, %v, %v)",
+			m.table, m.db.Dialect().Placeholder(1), m.db.Dialect().Placeholder(2), m.db.Dialect().Placeholder(3)), migration.Version, migration.Name, time.Now().UTC())
+	} else {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %v WHERE version = %v", m.table, m.db.Dialect().Placeholder(1)), migration.Version)
+	}
+	if err != nil {
+		return false, fmt.Errorf("recording migration %v_%v: %w", migration.Version, migration.Name, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("committing migration %v_%v: %w", migration.Version, migration.Name, err)
+	}
+	if up {
+		m.db.Infof("applied migration %v_%v of %v", migration.Version, migration.Name, m.owner)
+	} else {
+		m.db.Infof("reverted migration %v_%v of %v", migration.Version, migration.Name, m.owner)
+	}
+	return false, nil
+}
+
+// run applies (up) or reverts a migration
+func (m *Migrator) run(ctx context.Context, tx *sql.Tx, migration Migration, up bool) (err error) {
+	script, fn := migration.UpSQL, migration.Up
+	if !up {
+		script, fn = migration.DownSQL, migration.Down
+		if script == "" && fn == nil {
+			return fmt.Errorf("migration %v_%v cannot be reverted", migration.Version, migration.Name)
+		}
+	}
+
+	if fn != nil {
+		err = fn(ctx, tx)
+	} else if m.db.DriverName() == "mysql" {
+		// MySQL connections do not accept several statements at once by default
+		for _, statement := range splitSQLStatements(script) {
+			if _, err = tx.ExecContext(ctx, statement); err != nil {
+				break
+			}
+		}
+	} else {
+		_, err = tx.ExecContext(ctx, script)
+	}
+	if err != nil {
+		return fmt.Errorf("running migration %v_%v: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// next returns the first migration after version, or a zero Migration if there is none
+func (m *Migrator) next(version int64) Migration {
+	for _, migration := range m.migrations {
+		if migration.Version > version {
+			return migration
+		}
+	}
+	return Migration{}
+}
+
+// find returns the migration with the given version
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// ensureTable creates the versions table if it does not exist
+func (m *Migrator) ensureTable(ctx context.Context, exec Executor) error {
+	timestampType := "TIMESTAMP"
+	if m.db.DriverName() == "mysql" {
+		timestampType = "DATETIME"
+	}
+	_, err := exec.ExecContext(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %v (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at %v NOT NULL)",
+		m.table, timestampType))
+	if err != nil {
+		return fmt.Errorf("creating migrations table %v: %w", m.table, err)
+	}
+	return nil
+}
+
+// currentVersion returns the latest applied migration version
+func (m *Migrator) currentVersion(ctx context.Context, exec Executor) (int64, error) {
+	var version sql.NullInt64
+	err := exec.QueryRowContext(ctx, "SELECT MAX(version) FROM "+m.table).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("reading migrations version: %w", err)
+	}
+	return version.Int64, nil
+}
+
+// lock takes the migrations lock of the owner in the session conn and returns the function releasing it.
+// On sqlite, which has no session locks, each migration transaction takes the database write lock instead.
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (unlock func() error, err error) {
+	timeout := m.LockTimeout
+	if timeout <= 0 {
+		timeout = DefaultMigrationLockTimeout
+	}
+
+	switch m.db.DriverName() {
+	case "postgres":
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(m.table))
+		key := int64(hash.Sum64())
+		lockCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if _, err = conn.ExecContext(lockCtx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			return nil, fmt.Errorf("taking migrations lock: %w", err)
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+			return err
+		}, nil
+
+	case "mysql":
+		var acquired sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", m.table, int64(timeout.Seconds())).Scan(&acquired)
+		if err != nil {
+			return nil, fmt.Errorf("taking migrations lock: %w", err)
+		}
+		if acquired.Int64 != 1 {
+			return nil, fmt.Errorf("taking migrations lock: %w", context.DeadlineExceeded)
+		}
+		return func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", m.table)
+			return err
+		}, nil
+	}
+	return func() error { return nil }, nil
+}
+
+// splitSQLStatements splits a script on the semicolons which are not part of quoted strings, identifiers or comments
+func splitSQLStatements(script string) []string {
+	statements := make([]string, 0)
+	var current strings.Builder
+	var quote rune
+	lineComment, blockComment := false, false
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case lineComment:
+			if r == '\n' {
+				lineComment = false
+			}
+		case blockComment:
+			if r == '*' && next == '/' {
+				blockComment = false
+				current.WriteRune(r)
+				r = next
+				i++
+			}
+		case quote != 0:
+			if r == '\\' && next != 0 {
+				current.WriteRune(r)
+				r = next
+				i++
+			} else if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '-' && next == '-', r == '#':
+			lineComment = true
+		case r == '/' && next == '*':
+			blockComment = true
+		case r == ';':
+			if statement := strings.TrimSpace(current.String()); statement != "" {
+				statements = append(statements, statement)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if statement := strings.TrimSpace(current.String()); statement != "" {
+		statements = append(statements, statement)
+	}
+	return statements
+}
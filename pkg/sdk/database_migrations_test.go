@@ -0,0 +1,123 @@
+package sdk_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+var migrationsFS = fstest.MapFS{
+	"migrations/1_create_patients.up.sql":   {Data: []byte(createQuery)},
+	"migrations/1_create_patients.down.sql": {Data: []byte("DROP TABLE patients")},
+	"migrations/2_add_city.up.sql": {Data: []byte(`-- the city is optional; it may be unknown
+ALTER TABLE patients ADD COLUMN city text;
+CREATE INDEX patients_city ON patients(city);`)},
+	"migrations/2_add_city.down.sql": {Data: []byte("DROP INDEX patients_city; ALTER TABLE patients DROP COLUMN city;")},
+	"migrations/README.md":           {Data: []byte("ignored")},
+}
+
+func TestMigrationsFromFS(t *testing.T) {
+	migrations, err := sdk.MigrationsFromFS(migrationsFS, "migrations")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	require.Equal(t, int64(1), migrations[0].Version)
+	require.Equal(t, "create_patients", migrations[0].Name)
+	require.Equal(t, "add_city", migrations[1].Name)
+
+	_, err = sdk.MigrationsFromFS(fstest.MapFS{"m/1_only_down.down.sql": {}}, "m")
+	require.Error(t, err)
+	_, err = sdk.NewMigrator(nil, "invalid-owner", migrations...)
+	require.Error(t, err)
+	_, err = sdk.NewMigrator(nil, "plugin", migrations[0], migrations[0])
+	require.Error(t, err)
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{})
+	defer manager.Shutdown()
+	db, err := manager.NewDatabase(sdk.SQLiteConfig{Directory: t.TempDir(), Database: "test"})
+	require.NoError(t, err)
+
+	migrations, err := sdk.MigrationsFromFS(migrationsFS, "migrations")
+	require.NoError(t, err)
+	migrations = append(migrations, sdk.Migration{
+		Version: 3,
+		Name:    "seed",
+		Up: func(ctx context.Context, tx sdk.Executor) error {
+			_, err := tx.ExecContext(ctx, insertQuery, name, age, weight, height)
+			return err
+		},
+	})
+	migrator, err := sdk.NewMigrator(db, "plugin", migrations...)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, applied)
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), version)
+
+	// migrating again is a no-op
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Zero(t, applied)
+
+	// the seed migration cannot be reverted
+	require.Error(t, migrator.Down(ctx))
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), version)
+
+	_, err = db.Exec("DELETE FROM plugin_schema_migrations WHERE version = 3")
+	require.NoError(t, err)
+	require.NoError(t, migrator.Down(ctx))
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), version)
+
+	reverted, err := migrator.MigrateTo(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, reverted)
+	_, err = db.Exec(retrieveQuery)
+	require.Error(t, err)
+}
+
+func TestConcurrentMigrators(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	migrations, err := sdk.MigrationsFromFS(migrationsFS, "migrations")
+	require.NoError(t, err)
+
+	// several replicas migrating the same database apply each migration once
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	applied := make([]int, len(errs))
+	for i := range errs {
+		manager := sdk.NewDBManager(sdk.DBManagerConfig{})
+		defer manager.Shutdown()
+		db, err := manager.NewDatabase(sdk.SQLiteConfig{Directory: dir, Database: "test", BusyTimeoutMilliseconds: 10000})
+		require.NoError(t, err)
+		migrator, err := sdk.NewMigrator(db, "plugin", migrations...)
+		require.NoError(t, err)
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			applied[i], errs[i] = migrator.Up(ctx)
+		}(i)
+	}
+	wg.Wait()
+
+	total := 0
+	for i, err := range errs {
+		require.NoError(t, err)
+		total += applied[i]
+	}
+	require.Equal(t, len(migrations), total)
+}
@@ -0,0 +1,223 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// returningKeyword matches the RETURNING clause of a write statement, once its literals and quoted identifiers are masked (see maskLiterals)
+	returningKeyword = regexp.MustCompile(`(?i)\bRETURNING\b`)
+	// insertKeyword matches the statements whose last inserted ID is reported without RETURNING clause
+	insertKeyword = regexp.MustCompile(`(?i)^\s*(INSERT|REPLACE)\b`)
+)
+
+// WriteResult is the outcome of a write statement run with StoreResult, UpdateResult or DeleteResult
+type WriteResult struct {
+	// IDs holds the first column of every row returned by the RETURNING clause of the statement.
+	// Without RETURNING clause, it holds the ID of the last inserted record reported by the driver for INSERT statements if any
+	// (e.g. AUTO_INCREMENT on MySQL), and nothing for other statements.
+	IDs []string
+	// RowsAffected is the number of records written, the number of returned rows with a RETURNING clause
+	RowsAffected int64
+}
+
+// NotFound returns whether the statement matched no record, e.g. an UPDATE or DELETE whose WHERE clause matched nothing
+func (r WriteResult) NotFound() bool {
+	return r.RowsAffected == 0
+}
+
+// ID returns the first of the IDs, or "" if there are none
+func (r WriteResult) ID() string {
+	if len(r.IDs) == 0 {
+		return ""
+	}
+	return r.IDs[0]
+}
+
+// StoreResult stores records in the Database and returns the IDs of all the stored records.
+// sqlStatement is in the form of "INSERT INTO xxx VALUES ($1, $2, ...) [RETURNING id]".
+func (db *Database) StoreResult(sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	return db.StoreResultContext(context.Background(), sqlStatement, args...)
+}
+
+// StoreResultContext stores records in the Database and returns the IDs of all the stored records, the statement is cancelled if ctx is done
+func (db *Database) StoreResultContext(ctx context.Context, sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	ctx, op := db.startOperation(ctx, "StoreResult", sqlStatement)
+	defer func() { op.end(err) }()
+	err = db.waitReady(ctx, true)
+	if err != nil {
+		return
+	}
+	res, err = write(ctx, db.executor(), db.Dialect(), sqlStatement, args...)
+	if err != nil {
+		return res, NewDatabaseError(fmt.Errorf("recording record in db: %w", err))
+	}
+	return
+}
+
+// UpdateResult updates records in the Database and returns the IDs of all the updated records.
+// Unlike Update, no error is returned if no record matched, see WriteResult.NotFound.
+// sqlStatement is in the form of "UPDATE xxx SET xxx WHERE xxx [RETURNING id]".
+func (db *Database) UpdateResult(sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	return db.UpdateResultContext(context.Background(), sqlStatement, args...)
+}
+
+// UpdateResultContext updates records in the Database and returns the IDs of all the updated records, the statement is cancelled if ctx is done
+func (db *Database) UpdateResultContext(ctx context.Context, sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	ctx, op := db.startOperation(ctx, "UpdateResult", sqlStatement)
+	defer func() { op.end(err) }()
+	err = db.waitReady(ctx, true)
+	if err != nil {
+		return
+	}
+	res, err = write(ctx, db.executor(), db.Dialect(), sqlStatement, args...)
+	if err != nil {
+		return res, NewDatabaseError(fmt.Errorf("updating record(s) in db: %w", err))
+	}
+	db.Debugf("correctly updated %v record(s) with IDs: %v", res.RowsAffected, res.IDs)
+	return
+}
+
+// DeleteResult deletes records from the Database and returns the IDs of all the deleted records.
+// Unlike Delete, no error is returned if no record matched, see WriteResult.NotFound.
+// sqlStatement is in the form of "DELETE FROM xxx WHERE xxx [RETURNING id]".
+func (db *Database) DeleteResult(sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	return db.DeleteResultContext(context.Background(), sqlStatement, args...)
+}
+
+// DeleteResultContext deletes records from the Database and returns the IDs of all the deleted records, the statement is cancelled if ctx is done
+func (db *Database) DeleteResultContext(ctx context.Context, sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	ctx, op := db.startOperation(ctx, "DeleteResult", sqlStatement)
+	defer func() { op.end(err) }()
+	err = db.waitReady(ctx, true)
+	if err != nil {
+		return
+	}
+	res, err = write(ctx, db.executor(), db.Dialect(), sqlStatement, args...)
+	if err != nil {
+		return res, NewDatabaseError(fmt.Errorf("deleting record(s) from db: %w", err))
+	}
+	return
+}
+
+// StoreResult stores records as part of the transaction and returns the IDs of all the stored records
+func (tx *Tx) StoreResult(sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	return tx.StoreResultContext(tx.ctx, sqlStatement, args...)
+}
+
+// StoreResultContext stores records as part of the transaction and returns the IDs of all the stored records,
+// the statement is cancelled if ctx is done
+func (tx *Tx) StoreResultContext(ctx context.Context, sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	ctx, op := tx.db.startOperation(ctx, "StoreResult", sqlStatement)
+	defer func() { op.end(err) }()
+	res, err = write(ctx, tx.Tx, tx.db.Dialect(), sqlStatement, args...)
+	if err != nil {
+		return res, NewDatabaseError(fmt.Errorf("recording record in db: %w", err))
+	}
+	return
+}
+
+// UpdateResult updates records as part of the transaction and returns the IDs of all the updated records
+func (tx *Tx) UpdateResult(sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	return tx.UpdateResultContext(tx.ctx, sqlStatement, args...)
+}
+
+// UpdateResultContext updates records as part of the transaction and returns the IDs of all the updated records,
+// the statement is cancelled if ctx is done
+func (tx *Tx) UpdateResultContext(ctx context.Context, sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	ctx, op := tx.db.startOperation(ctx, "UpdateResult", sqlStatement)
+	defer func() { op.end(err) }()
+	res, err = write(ctx, tx.Tx, tx.db.Dialect(), sqlStatement, args...)
+	if err != nil {
+		return res, NewDatabaseError(fmt.Errorf("updating record(s) in db: %w", err))
+	}
+	tx.db.Debugf("correctly updated %v record(s) with IDs: %v", res.RowsAffected, res.IDs)
+	return
+}
+
+// DeleteResult deletes records as part of the transaction and returns the IDs of all the deleted records
+func (tx *Tx) DeleteResult(sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	return tx.DeleteResultContext(tx.ctx, sqlStatement, args...)
+}
+
+// DeleteResultContext deletes records as part of the transaction and returns the IDs of all the deleted records,
+// the statement is cancelled if ctx is done
+func (tx *Tx) DeleteResultContext(ctx context.Context, sqlStatement string, args ...interface{}) (res WriteResult, err error) {
+	ctx, op := tx.db.startOperation(ctx, "DeleteResult", sqlStatement)
+	defer func() { op.end(err) }()
+	res, err = write(ctx, tx.Tx, tx.db.Dialect(), sqlStatement, args...)
+	if err != nil {
+		return res, NewDatabaseError(fmt.Errorf("deleting record(s) from db: %w", err))
+	}
+	return
+}
+
+// write runs a write statement and returns its result. Statements with a RETURNING clause are run as queries if the dialect
+// supports it, the others are executed. "returning" in string literals or quoted identifiers is not a RETURNING clause.
+func write(ctx context.Context, q Executor, dialect Dialect, sqlStatement string, args ...interface{}) (WriteResult, error) {
+	if dialect.SupportsReturning() && returningKeyword.MatchString(maskLiterals(sqlStatement, dialect.Name(), true)) {
+		return writeReturning(ctx, q, sqlStatement, args...)
+	}
+
+	res, err := q.ExecContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return WriteResult{}, err
+	}
+	recordRowsAffected(ctx, affected)
+	result := WriteResult{RowsAffected: affected}
+	if !insertKeyword.MatchString(sqlStatement) || affected == 0 {
+		return result, nil
+	}
+	// drivers without last inserted IDs (e.g. postgres) return an error, MySQL returns 0 if no AUTO_INCREMENT value was generated
+	if lastID, err := res.LastInsertId(); err == nil && lastID != 0 {
+		result.IDs = []string{strconv.FormatInt(lastID, 10)}
+	}
+	return result, nil
+}
+
+// writeReturning runs a write statement with a RETURNING clause and returns the first column of the returned rows as IDs
+func writeReturning(ctx context.Context, q Executor, sqlStatement string, args ...interface{}) (result WriteResult, err error) {
+	rows, err := q.QueryContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+
+	var id sql.NullString
+	dest := make([]interface{}, len(columns))
+	for i := range dest {
+		dest[i] = new(sql.RawBytes)
+	}
+	if len(dest) > 0 {
+		dest[0] = &id
+	}
+	result.IDs = make([]string, 0)
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return result, fmt.Errorf("scanning returned ID: %w", err)
+		}
+		result.IDs = append(result.IDs, id.String)
+		result.RowsAffected++
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	recordRowsAffected(ctx, result.RowsAffected)
+	return
+}
@@ -0,0 +1,58 @@
+package sdk_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestWriteResults(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 3)
+
+	res, err := db.StoreResult(insertQuery+" RETURNING age", name, age+10, weight, height)
+	require.NoError(t, err)
+	require.Equal(t, sdk.WriteResult{IDs: []string{"68"}, RowsAffected: 1}, res)
+
+	// without RETURNING clause, the statement is executed and the last inserted rowid reported
+	res, err = db.StoreResult(insertQuery, name, age+20, weight, height)
+	require.NoError(t, err)
+	require.Equal(t, sdk.WriteResult{IDs: []string{"5"}, RowsAffected: 1}, res)
+
+	// "returning" in a literal or a quoted identifier is not a RETURNING clause
+	res, err = db.UpdateResult(`UPDATE patients SET name = 'returning customer' WHERE "age" = ?`, age+20)
+	require.NoError(t, err)
+	require.Equal(t, sdk.WriteResult{RowsAffected: 1}, res)
+	res, err = db.StoreResult(`INSERT INTO patients (name, age, weight, height) VALUES ('it''s returning', ?, ?, ?)`, age+20, weight, height)
+	require.NoError(t, err)
+	require.Equal(t, sdk.WriteResult{IDs: []string{"6"}, RowsAffected: 1}, res)
+	_, err = db.DeleteResult("DELETE FROM patients WHERE rowid = 6")
+	require.NoError(t, err)
+
+	res, err = db.UpdateResult("UPDATE patients SET weight = ? WHERE age < ? RETURNING age, name", weight+1, age+10)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"58", "59", "60"}, res.IDs)
+	require.Equal(t, int64(3), res.RowsAffected)
+	require.False(t, res.NotFound())
+
+	res, err = db.UpdateResult("UPDATE patients SET weight = ? WHERE age > ?", weight, age)
+	require.NoError(t, err)
+	require.Equal(t, sdk.WriteResult{RowsAffected: 4}, res)
+
+	res, err = db.UpdateResult("UPDATE patients SET weight = ? WHERE age = ? RETURNING age", weight, 0)
+	require.NoError(t, err)
+	require.True(t, res.NotFound())
+	require.Equal(t, "", res.ID())
+
+	err = db.WithTx(context.Background(), nil, func(tx *sdk.Tx) error {
+		res, err = tx.DeleteResult("DELETE FROM patients WHERE age >= ? RETURNING age", age+10)
+		return err
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"68", "78"}, res.IDs)
+
+	res, err = db.DeleteResult("DELETE FROM patients WHERE age >= ?", age+10)
+	require.NoError(t, err)
+	require.True(t, res.NotFound())
+}
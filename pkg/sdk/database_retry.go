@@ -0,0 +1,168 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	// ConstantRetryPolicy is the name of the ConstantBackOff retry policy in DBManagerConfig
+	ConstantRetryPolicy = "constant"
+	// ExponentialRetryPolicy is the name of the ExponentialBackOff retry policy in DBManagerConfig
+	ExponentialRetryPolicy = "exponential"
+
+	// DefaultRetryMultiplier is the growth factor of ExponentialBackOff intervals when none is configured
+	DefaultRetryMultiplier = 2.0
+)
+
+// RetryPolicy decides whether and after how long a failed connection attempt to a Database is retried
+type RetryPolicy interface {
+	// NextBackOff returns the time to wait before the next attempt given the number of attempts that failed so far
+	// and the time elapsed since the first one. ok is false if no further attempt should be made.
+	NextBackOff(failedAttempts int, elapsed time.Duration) (backoff time.Duration, ok bool)
+}
+
+// ConstantBackOff is a RetryPolicy waiting for the same Interval between attempts, up to MaxRetries times
+type ConstantBackOff struct {
+	Interval   time.Duration
+	MaxRetries int
+}
+
+// NextBackOff returns Interval as long as the number of failed attempts does not exceed MaxRetries
+func (b ConstantBackOff) NextBackOff(failedAttempts int, elapsed time.Duration) (time.Duration, bool) {
+	if failedAttempts > b.MaxRetries {
+		return 0, false
+	}
+	return b.Interval, true
+}
+
+// ExponentialBackOff is a RetryPolicy whose waiting time starts at InitialInterval and is multiplied by Multiplier
+// after each failed attempt, up to MaxInterval. Each interval is randomized by +/- RandomizationFactor (e.g. 0.2 for 20%)
+// to avoid several clients retrying in lockstep. Retrying stops after MaxRetries attempts or, if set, once MaxElapsedTime is reached.
+type ExponentialBackOff struct {
+	InitialInterval     time.Duration
+	MaxInterval         time.Duration
+	Multiplier          float64
+	RandomizationFactor float64
+	MaxElapsedTime      time.Duration
+	MaxRetries          int
+}
+
+// NextBackOff returns the randomized exponential interval for the next attempt
+func (b ExponentialBackOff) NextBackOff(failedAttempts int, elapsed time.Duration) (time.Duration, bool) {
+	if failedAttempts > b.MaxRetries {
+		return 0, false
+	}
+
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+	interval := float64(b.InitialInterval) * math.Pow(multiplier, float64(failedAttempts-1))
+	if b.MaxInterval > 0 && interval > float64(b.MaxInterval) {
+		interval = float64(b.MaxInterval)
+	}
+	if b.RandomizationFactor > 0 {
+		// #nosec G404 -- the jitter does not need a cryptographically secure source
+		interval += interval * b.RandomizationFactor * (2*rand.Float64() - 1)
+	}
+	backoff := time.Duration(interval)
+
+	if b.MaxElapsedTime > 0 && elapsed+backoff > b.MaxElapsedTime {
+		return 0, false
+	}
+	return backoff, true
+}
+
+// IsPermanentError returns whether err is known to not be resolved by retrying, e.g. an authentication failure,
+// an unknown database or a cancelled context. Errors that cannot be classified (e.g. *net.OpError) are considered transient.
+func IsPermanentError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return !isTransientPostgresError(pqErr)
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return !isTransientSQLiteError(sqliteErr)
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return !isTransientMySQLError(mysqlErr)
+	}
+	return false
+}
+
+// IsTransientError returns whether err may be resolved by retrying, it is the opposite of IsPermanentError
+func IsTransientError(err error) bool {
+	return err != nil && !IsPermanentError(err)
+}
+
+// IsAuthenticationError returns whether err is a rejection of the credentials by the database server
+func IsAuthenticationError(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// invalid_authorization_specification, invalid_password
+		return pqErr.Code == "28000" || pqErr.Code == "28P01"
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		// ER_ACCESS_DENIED_ERROR, ER_ACCESS_DENIED_NO_PASSWORD_ERROR
+		return mysqlErr.Number == 1045 || mysqlErr.Number == 1698
+	}
+	return false
+}
+
+// isTransientPostgresError returns whether a postgres server error is expected to be temporary
+func isTransientPostgresError(err *pq.Error) bool {
+	switch err.Code.Class() {
+	case "08", // connection_exception
+		"53": // insufficient_resources
+		return true
+	}
+	switch err.Code {
+	case "40001", // serialization_failure
+		"40P01", // deadlock_detected
+		"55P03", // lock_not_available
+		"57P01", // admin_shutdown
+		"57P02", // crash_shutdown
+		"57P03": // cannot_connect_now
+		return true
+	}
+	return false
+}
+
+// isTransientSQLiteError returns whether a sqlite error is expected to be temporary
+func isTransientSQLiteError(err sqlite3.Error) bool {
+	switch err.Code {
+	case sqlite3.ErrBusy, sqlite3.ErrLocked, sqlite3.ErrProtocol:
+		return true
+	}
+	return false
+}
+
+// isTransientMySQLError returns whether a MySQL server error is expected to be temporary
+func isTransientMySQLError(err *mysql.MySQLError) bool {
+	switch err.Number {
+	case 1040, // ER_CON_COUNT_ERROR
+		1053, // ER_SERVER_SHUTDOWN
+		1203, // ER_TOO_MANY_USER_CONNECTIONS
+		1205, // ER_LOCK_WAIT_TIMEOUT
+		1213: // ER_LOCK_DEADLOCK
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package sdk_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestExponentialBackOff(t *testing.T) {
+	policy := sdk.ExponentialBackOff{
+		InitialInterval:     100 * time.Millisecond,
+		MaxInterval:         time.Second,
+		Multiplier:          2,
+		RandomizationFactor: 0.5,
+		MaxRetries:          10,
+	}
+
+	for attempt, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		backoff, ok := policy.NextBackOff(attempt+1, 0)
+		require.True(t, ok)
+		require.GreaterOrEqual(t, backoff, expected/2)
+		require.LessOrEqual(t, backoff, expected*3/2)
+	}
+
+	_, ok := policy.NextBackOff(11, 0)
+	require.False(t, ok)
+
+	policy.MaxElapsedTime = 5 * time.Second
+	_, ok = policy.NextBackOff(1, 5*time.Second)
+	require.False(t, ok)
+}
+
+func TestIsPermanentError(t *testing.T) {
+	permanent := []error{
+		context.Canceled,
+		&pq.Error{Code: "28P01"}, // invalid_password
+		&pq.Error{Code: "3D000"}, // invalid_catalog_name
+		sqlite3.Error{Code: sqlite3.ErrCantOpen},
+		&mysql.MySQLError{Number: 1045}, // ER_ACCESS_DENIED_ERROR
+	}
+	for _, err := range permanent {
+		require.True(t, sdk.IsPermanentError(fmt.Errorf("wrapped: %w", err)), err)
+	}
+
+	transient := []error{
+		&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")},
+		&pq.Error{Code: "57P03"}, // cannot_connect_now
+		&pq.Error{Code: "08006"}, // connection_failure
+		sqlite3.Error{Code: sqlite3.ErrBusy},
+		&mysql.MySQLError{Number: 1040}, // ER_CON_COUNT_ERROR
+	}
+	for _, err := range transient {
+		require.True(t, sdk.IsTransientError(fmt.Errorf("wrapped: %w", err)), err)
+	}
+}
@@ -0,0 +1,231 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// TableKind is the kind of a Table
+type TableKind string
+
+const (
+	// TableKindTable is the kind of base tables
+	TableKindTable TableKind = "table"
+	// TableKindView is the kind of views
+	TableKindView TableKind = "view"
+)
+
+// Table identifies a table or view of a database
+type Table struct {
+	Schema string
+	Name   string
+	Kind   TableKind
+}
+
+// Column describes a column of a table or view
+type Column struct {
+	Name string
+	// Type is the SQL type of the column as declared in the database, e.g. "integer" or "varchar(255)"
+	Type     string
+	Nullable bool
+	// Default is the expression of the default value of the column, nil if it has none
+	Default *string
+	// PrimaryKey is the position (starting at 1) of the column in the primary key of its table, 0 if it is not part of it
+	PrimaryKey int
+}
+
+// TableDescription describes a table or view and its columns, in their order in the table
+type TableDescription struct {
+	Table
+	Columns []Column
+}
+
+// ColumnNames returns the names of the columns of the table, e.g. to fill MetadataStorage.Attributes
+func (td TableDescription) ColumnNames() []string {
+	names := make([]string, len(td.Columns))
+	for i, column := range td.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// PrimaryKey returns the names of the columns of the primary key of the table, in their order in the key
+func (td TableDescription) PrimaryKey() []string {
+	pk := make([]Column, 0)
+	for _, column := range td.Columns {
+		if column.PrimaryKey > 0 {
+			pk = append(pk, column)
+		}
+	}
+	sort.Slice(pk, func(i, j int) bool { return pk[i].PrimaryKey < pk[j].PrimaryKey })
+	names := make([]string, len(pk))
+	for i, column := range pk {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// ListSchemas returns the names of the schemas of the database, without the system ones.
+// On SQLite, schemas are the attached databases ("main", "temp", ...).
+func (db *Database) ListSchemas(ctx context.Context) (schemas []string, err error) {
+	err = db.WaitReadyContext(ctx)
+	if err != nil {
+		return
+	}
+
+	var query string
+	switch db.Dialect().(type) {
+	case SQLiteDialect:
+		query = "SELECT name FROM pragma_database_list ORDER BY seq"
+	case MySQLDialect:
+		query = "SELECT schema_name FROM information_schema.schemata " +
+			"WHERE schema_name NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys') ORDER BY schema_name"
+	default:
+		query = "SELECT schema_name FROM information_schema.schemata " +
+			"WHERE schema_name NOT IN ('pg_catalog', 'information_schema') AND schema_name NOT LIKE 'pg\\_%' ORDER BY schema_name"
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("listing schemas: %w", err))
+	}
+	defer rows.Close()
+	schemas = make([]string, 0)
+	for rows.Next() {
+		var schema string
+		if err = rows.Scan(&schema); err != nil {
+			return nil, NewDatabaseError(fmt.Errorf("listing schemas: %w", err))
+		}
+		schemas = append(schemas, schema)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("listing schemas: %w", err))
+	}
+	return schemas, nil
+}
+
+// ListTables returns the tables and views of schema, sorted by name.
+// If schema is empty, the default schema is used: the current schema on Postgres, the connected database on MySQL and "main" on SQLite.
+func (db *Database) ListTables(ctx context.Context, schema string) (tables []Table, err error) {
+	err = db.WaitReadyContext(ctx)
+	if err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	d := db.Dialect()
+	if _, ok := d.(SQLiteDialect); ok {
+		schema = valueOr(schema, "main")
+		rows, err = db.QueryContext(ctx, "SELECT ?, name, type FROM "+d.QuoteIdentifier(schema)+".sqlite_master "+
+			"WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite\\_%' ESCAPE '\\' ORDER BY name", schema)
+	} else {
+		rows, err = db.QueryContext(ctx, "SELECT table_schema, table_name, CASE WHEN table_type = 'VIEW' THEN 'view' ELSE 'table' END "+
+			"FROM information_schema.tables WHERE table_schema = "+defaultSchema(d, 1)+" ORDER BY table_name", schema)
+	}
+	if err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("listing tables of %v: %w", schema, err))
+	}
+	defer rows.Close()
+
+	tables = make([]Table, 0)
+	for rows.Next() {
+		var table Table
+		if err = rows.Scan(&table.Schema, &table.Name, &table.Kind); err != nil {
+			return nil, NewDatabaseError(fmt.Errorf("listing tables of %v: %w", schema, err))
+		}
+		tables = append(tables, table)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("listing tables of %v: %w", schema, err))
+	}
+	return tables, nil
+}
+
+// DescribeTable returns the description of the table or view of schema (the default schema if empty, see ListTables).
+// The returned error wraps sql.ErrNoRows if there is no such table.
+func (db *Database) DescribeTable(ctx context.Context, schema, table string) (*TableDescription, error) {
+	tables, err := db.ListTables(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tables {
+		if t.Name == table {
+			return db.describeTable(ctx, t)
+		}
+	}
+	return nil, NewDatabaseError(fmt.Errorf("describing table %v: %w", table, sql.ErrNoRows))
+}
+
+// DescribeSchema returns the description of all the tables and views of schema (the default schema if empty, see ListTables),
+// e.g. to expose the catalog of a data source
+func (db *Database) DescribeSchema(ctx context.Context, schema string) ([]TableDescription, error) {
+	tables, err := db.ListTables(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+	descriptions := make([]TableDescription, 0, len(tables))
+	for _, table := range tables {
+		description, err := db.describeTable(ctx, table)
+		if err != nil {
+			return nil, err
+		}
+		descriptions = append(descriptions, *description)
+	}
+	return descriptions, nil
+}
+
+// describeTable reads the columns of table
+func (db *Database) describeTable(ctx context.Context, table Table) (*TableDescription, error) {
+	var rows *sql.Rows
+	var err error
+	switch d := db.Dialect().(type) {
+	case SQLiteDialect:
+		rows, err = db.QueryContext(ctx, `SELECT name, type, "notnull" = 0, dflt_value, pk FROM pragma_table_info(?, ?) ORDER BY cid`,
+			table.Name, table.Schema)
+	default:
+		// the full type (e.g. "varchar(255)") is only available on MySQL
+		columnType := "c.data_type"
+		if _, ok := d.(MySQLDialect); ok {
+			columnType = "c.column_type"
+		}
+		rows, err = db.QueryContext(ctx, "SELECT c.column_name, "+columnType+", c.is_nullable = 'YES', c.column_default, COALESCE(k.ordinal_position, 0) "+
+			"FROM information_schema.columns c LEFT JOIN (information_schema.key_column_usage k JOIN information_schema.table_constraints tc "+
+			"ON tc.constraint_schema = k.constraint_schema AND tc.constraint_name = k.constraint_name "+
+			"AND tc.table_name = k.table_name AND tc.constraint_type = 'PRIMARY KEY') "+
+			"ON k.table_schema = c.table_schema AND k.table_name = c.table_name AND k.column_name = c.column_name "+
+			"WHERE c.table_schema = "+d.Placeholder(1)+" AND c.table_name = "+d.Placeholder(2)+" ORDER BY c.ordinal_position",
+			table.Schema, table.Name)
+	}
+	if err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("describing table %v: %w", table.Name, err))
+	}
+	defer rows.Close()
+
+	description := &TableDescription{Table: table, Columns: make([]Column, 0)}
+	for rows.Next() {
+		var column Column
+		var columnDefault sql.NullString
+		if err = rows.Scan(&column.Name, &column.Type, &column.Nullable, &columnDefault, &column.PrimaryKey); err != nil {
+			return nil, NewDatabaseError(fmt.Errorf("describing table %v: %w", table.Name, err))
+		}
+		if columnDefault.Valid {
+			column.Default = &columnDefault.String
+		}
+		description.Columns = append(description.Columns, column)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("describing table %v: %w", table.Name, err))
+	}
+	return description, nil
+}
+
+// defaultSchema returns the expression of the n-th statement parameter, or of the default schema if the parameter is empty
+func defaultSchema(d Dialect, n int) string {
+	current := "current_schema()"
+	if _, ok := d.(MySQLDialect); ok {
+		current = "DATABASE()"
+	}
+	return "COALESCE(NULLIF(" + d.Placeholder(n) + ", ''), " + current + ")"
+}
@@ -0,0 +1,51 @@
+package sdk_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestSchemaIntrospection(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 0)
+	_, err := db.Exec(`CREATE TABLE visits (patient text NOT NULL, day date NOT NULL, reason varchar(255) DEFAULT 'checkup', PRIMARY KEY (day, patient))`)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE VIEW adults AS SELECT name, age FROM patients WHERE age >= 18`)
+	require.NoError(t, err)
+
+	schemas, err := db.ListSchemas(ctx)
+	require.NoError(t, err)
+	require.Contains(t, schemas, "main")
+
+	tables, err := db.ListTables(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, []sdk.Table{
+		{Schema: "main", Name: "adults", Kind: sdk.TableKindView},
+		{Schema: "main", Name: "patients", Kind: sdk.TableKindTable},
+		{Schema: "main", Name: "visits", Kind: sdk.TableKindTable},
+	}, tables)
+
+	visits, err := db.DescribeTable(ctx, "main", "visits")
+	require.NoError(t, err)
+	require.Equal(t, []string{"patient", "day", "reason"}, visits.ColumnNames())
+	require.Equal(t, []string{"day", "patient"}, visits.PrimaryKey())
+	require.Equal(t, "varchar(255)", visits.Columns[2].Type)
+	require.False(t, visits.Columns[0].Nullable)
+	require.True(t, visits.Columns[2].Nullable)
+	require.Equal(t, "'checkup'", *visits.Columns[2].Default)
+	require.Nil(t, visits.Columns[0].Default)
+
+	_, err = db.DescribeTable(ctx, "", "unknown")
+	require.ErrorIs(t, err, sql.ErrNoRows)
+
+	catalog, err := db.DescribeSchema(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, catalog, 3)
+	require.Equal(t, []string{"name", "age"}, catalog[0].ColumnNames())
+	require.Equal(t, []string{"name", "age", "weight", "height"}, catalog[1].ColumnNames())
+	require.Empty(t, catalog[1].PrimaryKey())
+}
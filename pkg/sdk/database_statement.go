@@ -0,0 +1,296 @@
+package sdk
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// StatementBuilder builds INSERT, SELECT, UPDATE and DELETE statements in a Dialect.
+// Table and column names are quoted and values are always passed as statement parameters, never inlined.
+// Table names may be schema-qualified ("schema.table").
+type StatementBuilder struct {
+	Dialect Dialect
+}
+
+// NewStatementBuilder returns a StatementBuilder for dialect
+func NewStatementBuilder(dialect Dialect) StatementBuilder {
+	return StatementBuilder{Dialect: dialect}
+}
+
+// Dialect returns the Dialect of the Database
+func (db *Database) Dialect() Dialect {
+	return DialectOf(db.DatabaseConfig)
+}
+
+// Builder returns a StatementBuilder in the Dialect of the Database
+func (db *Database) Builder() StatementBuilder {
+	return NewStatementBuilder(db.Dialect())
+}
+
+// Insert starts an INSERT statement into table
+func (b StatementBuilder) Insert(table string) *InsertStatement {
+	return &InsertStatement{dialect: b.Dialect, table: table}
+}
+
+// Select starts a SELECT statement of columns (all columns if none) from table
+func (b StatementBuilder) Select(table string, columns ...string) *SelectStatement {
+	return &SelectStatement{dialect: b.Dialect, table: table, columns: columns, limit: -1}
+}
+
+// Update starts an UPDATE statement of table
+func (b StatementBuilder) Update(table string) *UpdateStatement {
+	return &UpdateStatement{dialect: b.Dialect, table: table}
+}
+
+// Delete starts a DELETE statement from table
+func (b StatementBuilder) Delete(table string) *DeleteStatement {
+	return &DeleteStatement{dialect: b.Dialect, table: table}
+}
+
+// InsertStatement is an INSERT statement being built
+type InsertStatement struct {
+	dialect   Dialect
+	table     string
+	columns   []string
+	values    []interface{}
+	returning string
+}
+
+// Value sets the value of column in the inserted record
+func (s *InsertStatement) Value(column string, value interface{}) *InsertStatement {
+	s.columns = append(s.columns, column)
+	s.values = append(s.values, value)
+	return s
+}
+
+// Returning sets the column returned by the statement, ignored by dialects without RETURNING support
+func (s *InsertStatement) Returning(column string) *InsertStatement {
+	s.returning = column
+	return s
+}
+
+// Build returns the statement and its parameters
+func (s *InsertStatement) Build() (query string, args []interface{}, err error) {
+	if len(s.columns) == 0 {
+		return "", nil, fmt.Errorf("building insert into %v: no values", s.table)
+	}
+	columns := make([]string, len(s.columns))
+	placeholders := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		columns[i] = s.dialect.QuoteIdentifier(column)
+		placeholders[i] = s.dialect.Placeholder(i + 1)
+	}
+	query = fmt.Sprintf("INSERT INTO %v (%v) VALUES (%v)", quoteQualifiedIdentifier(s.dialect, s.table),
+		strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+	return query + returningClause(s.dialect, s.returning), s.values, nil
+}
+
+// SelectStatement is a SELECT statement being built
+type SelectStatement struct {
+	dialect Dialect
+	table   string
+	columns []string
+	where   conditions
+	orderBy []string
+	limit   int64
+	offset  int64
+}
+
+// Where restricts the selected records to the ones whose column compares to value with operator (see Operators)
+func (s *SelectStatement) Where(column, operator string, value interface{}) *SelectStatement {
+	s.where = append(s.where, condition{column, operator, value})
+	return s
+}
+
+// OrderBy sorts the selected records by column, after the previously given ones
+func (s *SelectStatement) OrderBy(column string, descending bool) *SelectStatement {
+	order := s.dialect.QuoteIdentifier(column)
+	if descending {
+		order += " DESC"
+	}
+	s.orderBy = append(s.orderBy, order)
+	return s
+}
+
+// Limit restricts the number of selected records
+func (s *SelectStatement) Limit(limit int64) *SelectStatement {
+	s.limit = limit
+	return s
+}
+
+// Offset skips the first offset selected records
+func (s *SelectStatement) Offset(offset int64) *SelectStatement {
+	s.offset = offset
+	return s
+}
+
+// Build returns the statement and its parameters
+func (s *SelectStatement) Build() (query string, args []interface{}, err error) {
+	columns := "*"
+	if len(s.columns) > 0 {
+		quoted := make([]string, len(s.columns))
+		for i, column := range s.columns {
+			quoted[i] = s.dialect.QuoteIdentifier(column)
+		}
+		columns = strings.Join(quoted, ", ")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT " + columns + " FROM " + quoteQualifiedIdentifier(s.dialect, s.table))
+	args, err = s.where.build(&sb, s.dialect, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("building select from %v: %w", s.table, err)
+	}
+	if len(s.orderBy) > 0 {
+		sb.WriteString(" ORDER BY " + strings.Join(s.orderBy, ", "))
+	}
+	if clause := s.dialect.LimitOffset(s.limit, s.offset); clause != "" {
+		sb.WriteString(" " + clause)
+	}
+	return sb.String(), args, nil
+}
+
+// UpdateStatement is an UPDATE statement being built
+type UpdateStatement struct {
+	dialect   Dialect
+	table     string
+	columns   []string
+	values    []interface{}
+	where     conditions
+	returning string
+}
+
+// Set sets column to value in the updated records
+func (s *UpdateStatement) Set(column string, value interface{}) *UpdateStatement {
+	s.columns = append(s.columns, column)
+	s.values = append(s.values, value)
+	return s
+}
+
+// Where restricts the updated records to the ones whose column compares to value with operator (see Operators)
+func (s *UpdateStatement) Where(column, operator string, value interface{}) *UpdateStatement {
+	s.where = append(s.where, condition{column, operator, value})
+	return s
+}
+
+// Returning sets the column returned by the statement, ignored by dialects without RETURNING support
+func (s *UpdateStatement) Returning(column string) *UpdateStatement {
+	s.returning = column
+	return s
+}
+
+// Build returns the statement and its parameters
+func (s *UpdateStatement) Build() (query string, args []interface{}, err error) {
+	if len(s.columns) == 0 {
+		return "", nil, fmt.Errorf("building update of %v: no values", s.table)
+	}
+	assignments := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		assignments[i] = s.dialect.QuoteIdentifier(column) + " = " + s.dialect.Placeholder(i+1)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("UPDATE " + quoteQualifiedIdentifier(s.dialect, s.table) + " SET " + strings.Join(assignments, ", "))
+	args, err = s.where.build(&sb, s.dialect, append([]interface{}(nil), s.values...))
+	if err != nil {
+		return "", nil, fmt.Errorf("building update of %v: %w", s.table, err)
+	}
+	return sb.String() + returningClause(s.dialect, s.returning), args, nil
+}
+
+// DeleteStatement is a DELETE statement being built
+type DeleteStatement struct {
+	dialect   Dialect
+	table     string
+	where     conditions
+	returning string
+}
+
+// Where restricts the deleted records to the ones whose column compares to value with operator (see Operators)
+func (s *DeleteStatement) Where(column, operator string, value interface{}) *DeleteStatement {
+	s.where = append(s.where, condition{column, operator, value})
+	return s
+}
+
+// Returning sets the column returned by the statement, ignored by dialects without RETURNING support
+func (s *DeleteStatement) Returning(column string) *DeleteStatement {
+	s.returning = column
+	return s
+}
+
+// Build returns the statement and its parameters
+func (s *DeleteStatement) Build() (query string, args []interface{}, err error) {
+	var sb strings.Builder
+	sb.WriteString("DELETE FROM " + quoteQualifiedIdentifier(s.dialect, s.table))
+	args, err = s.where.build(&sb, s.dialect, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("building delete from %v: %w", s.table, err)
+	}
+	return sb.String() + returningClause(s.dialect, s.returning), args, nil
+}
+
+// Operators are the comparison operators accepted in Where conditions.
+// The value of "IN" and "NOT IN" must be a slice, the value of "IS NULL" and "IS NOT NULL" is ignored.
+var Operators = []string{"=", "<>", "!=", "<", "<=", ">", ">=", "LIKE", "NOT LIKE", "IN", "NOT IN", "IS NULL", "IS NOT NULL"}
+
+// condition is a comparison of a column to a value
+type condition struct {
+	column   string
+	operator string
+	value    interface{}
+}
+
+// conditions are ANDed conditions of a WHERE clause
+type conditions []condition
+
+// build writes the WHERE clause to sb and returns args followed by the parameters of the conditions
+func (cs conditions) build(sb *strings.Builder, d Dialect, args []interface{}) ([]interface{}, error) {
+	for i, c := range cs {
+		if i == 0 {
+			sb.WriteString(" WHERE ")
+		} else {
+			sb.WriteString(" AND ")
+		}
+		column := d.QuoteIdentifier(c.column)
+
+		switch operator := strings.ToUpper(strings.TrimSpace(c.operator)); operator {
+		case "=", "<>", "!=", "<", "<=", ">", ">=", "LIKE", "NOT LIKE":
+			args = append(args, c.value)
+			sb.WriteString(column + " " + operator + " " + d.Placeholder(len(args)))
+		case "IS NULL", "IS NOT NULL":
+			sb.WriteString(column + " " + operator)
+		case "IN", "NOT IN":
+			values := reflect.ValueOf(c.value)
+			if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+				return nil, fmt.Errorf("value of %v condition on %v must be a slice", operator, c.column)
+			}
+			if values.Len() == 0 {
+				// nothing is in an empty set
+				if operator == "IN" {
+					sb.WriteString("1 = 0")
+				} else {
+					sb.WriteString("1 = 1")
+				}
+				continue
+			}
+			placeholders := make([]string, values.Len())
+			for j := range placeholders {
+				args = append(args, values.Index(j).Interface())
+				placeholders[j] = d.Placeholder(len(args))
+			}
+			sb.WriteString(column + " " + operator + " (" + strings.Join(placeholders, ", ") + ")")
+		default:
+			return nil, fmt.Errorf("unsupported operator %q on %v", c.operator, c.column)
+		}
+	}
+	return args, nil
+}
+
+// returningClause returns the RETURNING clause of column, or "" if there is none or the dialect does not support it
+func returningClause(d Dialect, column string) string {
+	if column == "" || !d.SupportsReturning() {
+		return ""
+	}
+	return " RETURNING " + d.QuoteIdentifier(column)
+}
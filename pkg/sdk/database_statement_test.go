@@ -0,0 +1,83 @@
+package sdk_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestDialects(t *testing.T) {
+	require.IsType(t, sdk.PostgresDialect{}, sdk.DialectOf(sdk.PostgresConfig{}))
+	require.IsType(t, sdk.SQLiteDialect{}, sdk.DialectOf(sdk.SQLiteConfig{}))
+	require.IsType(t, sdk.MySQLDialect{}, sdk.DialectOf(sdk.MySQLConfig{}))
+	require.IsType(t, sdk.SQLiteDialect{}, sdk.DialectOf(credentialedSQLiteConfig{}))
+
+	ts := time.Date(2023, 4, 5, 6, 7, 8, 0, time.FixedZone("CEST", 2*3600))
+	for _, tc := range []struct {
+		dialect     sdk.Dialect
+		placeholder string
+		quoted      string
+		offsetOnly  string
+		literals    []string
+	}{
+		{sdk.PostgresDialect{}, "$2", `"we""ird"`, "LIMIT ALL OFFSET 5", []string{"TRUE", "TIMESTAMP WITH TIME ZONE '2023-04-05 06:07:08+02:00'"}},
+		{sdk.SQLiteDialect{}, "?", `"we""ird"`, "LIMIT -1 OFFSET 5", []string{"1", "'2023-04-05 06:07:08+02:00'"}},
+		{sdk.MySQLDialect{}, "?", "`we\"ird`", "LIMIT 18446744073709551615 OFFSET 5", []string{"TRUE", "TIMESTAMP '2023-04-05 04:07:08'"}},
+	} {
+		require.Equal(t, tc.placeholder, tc.dialect.Placeholder(2), tc.dialect.Name())
+		require.Equal(t, tc.quoted, tc.dialect.QuoteIdentifier(`we"ird`), tc.dialect.Name())
+		require.Equal(t, tc.offsetOnly, tc.dialect.LimitOffset(-1, 5), tc.dialect.Name())
+		require.Equal(t, "LIMIT 10", tc.dialect.LimitOffset(10, 0), tc.dialect.Name())
+		require.Empty(t, tc.dialect.LimitOffset(-1, 0), tc.dialect.Name())
+		require.Equal(t, tc.literals, []string{tc.dialect.BooleanLiteral(true), tc.dialect.TimestampLiteral(ts)}, tc.dialect.Name())
+	}
+	require.Equal(t, "`a``b`", sdk.MySQLDialect{}.QuoteIdentifier("a`b"))
+}
+
+func TestStatementBuilder(t *testing.T) {
+	pg := sdk.NewStatementBuilder(sdk.PostgresDialect{})
+	query, args, err := pg.Update("public.patients").Set("age", 59).Where("name", "=", name).Where("id", "in", []int{1, 2}).Returning("id").Build()
+	require.NoError(t, err)
+	require.Equal(t, `UPDATE "public"."patients" SET "age" = $1 WHERE "name" = $2 AND "id" IN ($3, $4) RETURNING "id"`, query)
+	require.Equal(t, []interface{}{59, name, 1, 2}, args)
+
+	my := sdk.NewStatementBuilder(sdk.MySQLDialect{})
+	query, args, err = my.Insert("patients").Value("name", name).Value("age", age).Returning("id").Build()
+	require.NoError(t, err)
+	require.Equal(t, "INSERT INTO `patients` (`name`, `age`) VALUES (?, ?)", query)
+	require.Equal(t, []interface{}{name, age}, args)
+
+	query, args, err = my.Select("patients").Where("height", "IS NOT NULL", nil).Where("id", "NOT IN", []int{}).OrderBy("age", true).Limit(10).Offset(20).Build()
+	require.NoError(t, err)
+	require.Equal(t, "SELECT * FROM `patients` WHERE `height` IS NOT NULL AND 1 = 1 ORDER BY `age` DESC LIMIT 10 OFFSET 20", query)
+	require.Empty(t, args)
+
+	_, _, err = pg.Delete("patients").Where("name", "= 1; DROP TABLE patients; --", name).Build()
+	require.Error(t, err)
+	_, _, err = pg.Delete("patients").Where("id", "IN", 1).Build()
+	require.Error(t, err)
+	_, _, err = pg.Insert("patients").Build()
+	require.Error(t, err)
+}
+
+func TestStatementBuilderSQLite(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 0)
+
+	query, args, err := db.Builder().Insert("patients").Value("name", name).Value("age", age).Value("weight", weight).Value("height", height).Returning("name").Build()
+	require.NoError(t, err)
+	id, err := db.Store(query, args...)
+	require.NoError(t, err)
+	require.Equal(t, name, id)
+
+	query, args, err = db.Builder().Select("patients", "age").Where("name", "LIKE", "Albert%").Limit(1).Build()
+	require.NoError(t, err)
+	rows, err := db.Retrieve(query, args...)
+	require.NoError(t, err)
+	defer rows.Close()
+	var actAge int
+	require.True(t, rows.Next())
+	require.NoError(t, rows.Scan(&actAge))
+	require.Equal(t, age, actAge)
+}
@@ -0,0 +1,228 @@
+package sdk
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// StatementCacheStats holds the statistics of the prepared statement cache of a Database
+type StatementCacheStats struct {
+	// Size is the number of cached statements and Capacity the maximum number of cached statements
+	Size     int
+	Capacity int
+	// Hits and Misses count the statements found and not found in the cache
+	Hits   uint64
+	Misses uint64
+	// Evictions counts the statements removed from the cache to make room for others, because their execution failed
+	// or because the database was reconnected
+	Evictions uint64
+}
+
+// EnableStatementCache makes the Database prepare the statements of Store, Update, Retrieve and Delete once and keep the
+// capacity most recently used ones, so that they are not parsed again by the server at each call.
+// A capacity of 0 disables the cache. Statements run as part of a transaction are not cached.
+func (db *Database) EnableStatementCache(capacity int) {
+	var primary, replica *statementCache
+	if capacity > 0 {
+		primary = newStatementCache(db.DB, capacity)
+		if db.Replica != nil {
+			replica = newStatementCache(db.Replica, capacity)
+		}
+	}
+	db.stmtCacheMu.Lock()
+	previous, previousReplica := db.stmtCache, db.replicaStmtCache
+	db.stmtCache, db.replicaStmtCache = primary, replica
+	db.stmtCacheMu.Unlock()
+	previous.reset()
+	previousReplica.reset()
+}
+
+// StatementCacheStats returns the statistics of the prepared statement cache, which are zero if it is disabled
+func (db *Database) StatementCacheStats() StatementCacheStats {
+	db.stmtCacheMu.RLock()
+	defer db.stmtCacheMu.RUnlock()
+	stats := db.stmtCache.stats()
+	replicaStats := db.replicaStmtCache.stats()
+	stats.Size += replicaStats.Size
+	stats.Hits += replicaStats.Hits
+	stats.Misses += replicaStats.Misses
+	stats.Evictions += replicaStats.Evictions
+	return stats
+}
+
+// resetStatementCache closes the cached statements, e.g. after reconnecting to the database
+func (db *Database) resetStatementCache() {
+	db.stmtCacheMu.RLock()
+	defer db.stmtCacheMu.RUnlock()
+	db.stmtCache.reset()
+	db.replicaStmtCache.reset()
+}
+
+// executor returns the Executor of write statements, which prepares them through the statement cache if enabled
+func (db *Database) executor() Executor {
+	db.stmtCacheMu.RLock()
+	defer db.stmtCacheMu.RUnlock()
+	if db.stmtCache != nil {
+		return db.stmtCache
+	}
+	return db.DB
+}
+
+// readExecutor returns the Executor of read statements, on the read replicas if any
+func (db *Database) readExecutor() Executor {
+	if db.Replica == nil {
+		return db.executor()
+	}
+	db.stmtCacheMu.RLock()
+	defer db.stmtCacheMu.RUnlock()
+	if db.replicaStmtCache != nil {
+		return db.replicaStmtCache
+	}
+	return db.Replica
+}
+
+// statementCache is an LRU cache of the prepared statements of a sql.DB, it is an Executor running statements through the cache
+type statementCache struct {
+	db       *sql.DB
+	capacity int
+
+	mu sync.Mutex
+	// lru holds the cached statements from the most to the least recently used one, indexed by their query in statements
+	lru        *list.List
+	statements map[string]*list.Element
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+}
+
+// cachedStatement is an entry of a statementCache
+type cachedStatement struct {
+	query string
+	stmt  *sql.Stmt
+	// users is the number of executions of the statement being started, removed is true once it is no longer cached.
+	// Removed statements are closed once they have no users, database/sql keeps them open until their rows are closed.
+	users   int
+	removed bool
+}
+
+// newStatementCache returns an empty cache of up to capacity statements of db
+func newStatementCache(db *sql.DB, capacity int) *statementCache {
+	return &statementCache{db: db, capacity: capacity, lru: list.New(), statements: make(map[string]*list.Element)}
+}
+
+// ExecContext executes the prepared statement of query
+func (c *statementCache) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	cached, err := c.acquire(ctx, query)
+	if err != nil {
+		return c.db.ExecContext(ctx, query, args...)
+	}
+	res, err := cached.stmt.ExecContext(ctx, args...)
+	c.release(cached, err != nil)
+	return res, err
+}
+
+// QueryContext executes the prepared statement of query and returns its rows
+func (c *statementCache) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	cached, err := c.acquire(ctx, query)
+	if err != nil {
+		return c.db.QueryContext(ctx, query, args...)
+	}
+	rows, err := cached.stmt.QueryContext(ctx, args...)
+	c.release(cached, err != nil)
+	return rows, err
+}
+
+// QueryRowContext executes the prepared statement of query and returns its first row
+func (c *statementCache) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	cached, err := c.acquire(ctx, query)
+	if err != nil {
+		// the error is reported by the row
+		return c.db.QueryRowContext(ctx, query, args...)
+	}
+	row := cached.stmt.QueryRowContext(ctx, args...)
+	c.release(cached, row.Err() != nil)
+	return row
+}
+
+// acquire returns the cached statement of query, preparing and caching it if needed. It must be released once executed.
+func (c *statementCache) acquire(ctx context.Context, query string) (*cachedStatement, error) {
+	c.mu.Lock()
+	if element, ok := c.statements[query]; ok {
+		c.hits++
+		c.lru.MoveToFront(element)
+		cached := element.Value.(*cachedStatement)
+		cached.users++
+		c.mu.Unlock()
+		return cached, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.statements[query]; ok {
+		// prepared concurrently by another caller
+		_ = stmt.Close()
+		cached := element.Value.(*cachedStatement)
+		cached.users++
+		return cached, nil
+	}
+	cached := &cachedStatement{query: query, stmt: stmt, users: 1}
+	c.statements[query] = c.lru.PushFront(cached)
+	for c.lru.Len() > c.capacity {
+		c.remove(c.lru.Back())
+	}
+	return cached, nil
+}
+
+// release ends the use of a statement returned by acquire, the statement is removed from the cache if its execution failed
+func (c *statementCache) release(cached *cachedStatement, failed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached.users--
+	if failed && !cached.removed {
+		c.remove(c.statements[cached.query])
+	} else if cached.removed && cached.users == 0 {
+		_ = cached.stmt.Close()
+	}
+}
+
+// remove removes a statement from the cache, which must be locked, and closes it if it is not in use
+func (c *statementCache) remove(element *list.Element) {
+	cached := c.lru.Remove(element).(*cachedStatement)
+	delete(c.statements, cached.query)
+	cached.removed = true
+	c.evictions++
+	if cached.users == 0 {
+		_ = cached.stmt.Close()
+	}
+}
+
+// reset closes and removes all the statements from the cache, it is a no-op on a nil cache
+func (c *statementCache) reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		c.remove(c.lru.Back())
+	}
+}
+
+// stats returns the statistics of the cache, zero on a nil cache
+func (c *statementCache) stats() StatementCacheStats {
+	if c == nil {
+		return StatementCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return StatementCacheStats{Size: c.lru.Len(), Capacity: c.capacity, Hits: c.hits, Misses: c.misses, Evictions: c.evictions}
+}
@@ -0,0 +1,58 @@
+package sdk_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestStatementCache(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{StatementCacheSize: 2}, sdk.SQLiteConfig{}, 0)
+
+	storeQuery := insertQuery + " RETURNING name"
+	for i := 0; i < 3; i++ {
+		_, err := db.Store(storeQuery, name, age+i, weight, height)
+		require.NoError(t, err)
+	}
+	require.Equal(t, sdk.StatementCacheStats{Size: 1, Capacity: 2, Hits: 2, Misses: 1}, db.StatementCacheStats())
+
+	// concurrent retrievals share the prepared statement
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rows, err := db.Retrieve(retrieveQuery)
+			require.NoError(t, err)
+			count := 0
+			for rows.Next() {
+				count++
+			}
+			require.NoError(t, rows.Err())
+			require.Equal(t, 3, count)
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 2, db.StatementCacheStats().Size)
+
+	// the least recently used statement is evicted
+	_, err := db.Update("UPDATE patients SET age = ? WHERE age = ? RETURNING name", age, age+2)
+	require.NoError(t, err)
+	stats := db.StatementCacheStats()
+	require.Equal(t, 2, stats.Size)
+	require.Equal(t, uint64(1), stats.Evictions)
+
+	// failed statements are evicted and their errors reported
+	_, err = db.Store(storeQuery, name, age)
+	require.Error(t, err)
+	_, err = db.Store("INSERT INTO unknown VALUES (?)", name)
+	require.Error(t, err)
+	require.Equal(t, 1, db.StatementCacheStats().Size)
+
+	db.EnableStatementCache(0)
+	require.Equal(t, sdk.StatementCacheStats{}, db.StatementCacheStats())
+	_, err = db.Store(storeQuery, name, age, weight, height)
+	require.NoError(t, err)
+}
@@ -0,0 +1,385 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStreamBatchSize is the number of rows per batch of a RowStream when none is configured
+const DefaultStreamBatchSize = 1000
+
+// lastCursorID numbers the server-side cursors opened by RowStreams
+var lastCursorID atomic.Uint64
+
+// StreamOptions holds the options of a RowStream opened with Database.Stream
+type StreamOptions struct {
+	// BatchSize is the maximum number of rows returned by RowStream.NextBatch, and of rows fetched at once from
+	// server-side cursors. DefaultStreamBatchSize is used if 0.
+	BatchSize int
+	// DisableCursor disables the server-side cursor used on Postgres, so that the whole result set is sent at once by the server
+	DisableCursor bool
+}
+
+// RowStream iterates over the rows of a query without loading them all in memory.
+// On Postgres, the rows are fetched BatchSize at a time from a server-side cursor in a read-only transaction.
+// The stream is closed once all rows are read, when an error occurs or when its context is done,
+// it must otherwise be closed with Close, e.g. when stopping early.
+// The query timeout of the Database (see QueryLimits) covers the whole stream, including all the fetches from the cursor.
+type RowStream struct {
+	ctx       context.Context
+	batchSize int
+
+	rows        *sql.Rows
+	columns     []string
+	columnTypes []*sql.ColumnType
+	values      []interface{}
+	err         error
+	closed      bool
+
+	// tx and cursor are the transaction and name of the server-side cursor, if any, and fetched the number of rows of its last fetch
+	tx      *sql.Tx
+	cursor  string
+	fetched int
+	// limiter accounts for the rows of all the fetches from the cursor, which are otherwise limited one fetch at a time
+	limiter *resultLimiter
+	// limits are the query limits of the database, whose timeout is set once on ctx for all the fetches from the cursor if timeoutSet,
+	// cancel releasing it
+	limits     QueryLimits
+	timeoutSet bool
+	cancel     context.CancelFunc
+	// op is the operation of the stream, which ends when the stream is closed
+	op operation
+}
+
+// Stream runs a query and returns a RowStream over its rows.
+// If opts is nil, the rows are fetched DefaultStreamBatchSize at a time.
+// The operation is traced and measured (see Database.EnableTracing) until the stream is closed.
+func (db *Database) Stream(ctx context.Context, opts *StreamOptions, sqlStatement string, args ...interface{}) (stream *RowStream, err error) {
+	if opts == nil {
+		opts = &StreamOptions{}
+	}
+	ctx, op := db.startOperation(ctx, "Stream", sqlStatement)
+	err = db.waitReady(ctx, true)
+	if err != nil {
+		op.end(err)
+		return
+	}
+
+	stream = &RowStream{ctx: ctx, batchSize: opts.BatchSize, op: op}
+	if stream.batchSize <= 0 {
+		stream.batchSize = DefaultStreamBatchSize
+	}
+
+	if _, ok := db.Dialect().(PostgresDialect); ok && !opts.DisableCursor {
+		// the statements run on the cursor would otherwise each get their own timeout
+		stream.limits = db.limits
+		ctx, stream.cancel, stream.timeoutSet = db.limits.withTimeout(ctx)
+		stream.ctx = ctx
+		stream.tx, err = db.reader().BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+		if err != nil {
+			stream.cancel()
+			err = NewDatabaseError(fmt.Errorf("beginning cursor transaction: %w", err))
+			op.end(err)
+			return nil, err
+		}
+		stream.cursor = "sdk_cursor_" + strconv.FormatUint(lastCursorID.Add(1), 10)
+		if db.limits.MaxResultRows > 0 || db.limits.MaxResultBytes > 0 {
+			stream.limiter = &resultLimiter{limits: db.limits}
+		}
+		_, err = stream.tx.ExecContext(ctx, "DECLARE "+stream.cursor+" NO SCROLL CURSOR FOR "+sqlStatement, args...)
+		if err != nil {
+			stream.fail(fmt.Errorf("declaring cursor: %w", err))
+			return nil, stream.err
+		}
+		err = stream.fetch()
+	} else {
+		stream.rows, err = retrieve(ctx, db.readExecutor(), sqlStatement, args...)
+	}
+	if err != nil {
+		stream.fail(err)
+		return nil, stream.err
+	}
+
+	stream.columnTypes, err = stream.rows.ColumnTypes()
+	if err != nil {
+		stream.fail(fmt.Errorf("reading columns: %w", err))
+		return nil, stream.err
+	}
+	stream.columns = make([]string, len(stream.columnTypes))
+	for i, ct := range stream.columnTypes {
+		stream.columns[i] = ct.Name()
+	}
+	return stream, nil
+}
+
+// Columns returns the names of the columns of the rows
+func (s *RowStream) Columns() []string {
+	return s.columns
+}
+
+// ColumnTypes returns the types of the columns of the rows
+func (s *RowStream) ColumnTypes() []*sql.ColumnType {
+	return s.columnTypes
+}
+
+// Next advances to the next row, it returns false and closes the stream once there are no more rows or an error occurred (see Err)
+func (s *RowStream) Next() bool {
+	if s.closed {
+		return false
+	}
+	for !s.rows.Next() {
+		if err := s.rows.Err(); err != nil {
+			s.fail(fmt.Errorf("reading rows: %w", err))
+			return false
+		}
+		// the last fetch from the cursor was complete, there may be more rows
+		if s.cursor == "" || s.fetched < s.batchSize {
+			s.fail(nil)
+			return false
+		}
+		if err := s.rows.Close(); err != nil {
+			s.fail(fmt.Errorf("closing fetched rows: %w", err))
+			return false
+		}
+		if err := s.fetch(); err != nil {
+			s.fail(err)
+			return false
+		}
+	}
+	if s.cursor != "" {
+		s.fetched++
+	}
+
+	s.values = make([]interface{}, len(s.columns))
+	dest := make([]interface{}, len(s.columns))
+	for i := range dest {
+		dest[i] = &s.values[i]
+	}
+	if err := s.rows.Scan(dest...); err != nil {
+		s.fail(fmt.Errorf("scanning row: %w", err))
+		return false
+	}
+	if s.limiter != nil {
+		values := make([]driver.Value, len(s.values))
+		for i, value := range s.values {
+			values[i] = value
+		}
+		if err := s.limiter.add(values); err != nil {
+			s.fail(fmt.Errorf("reading rows: %w", err))
+			return false
+		}
+	}
+	return true
+}
+
+// Values returns the values of the current row as returned by the driver, e.g. int64, float64, string, []byte, bool, time.Time or nil for NULL
+func (s *RowStream) Values() []interface{} {
+	return s.values
+}
+
+// Scan copies the values of the current row into dest like sql.Rows.Scan
+func (s *RowStream) Scan(dest ...interface{}) error {
+	if len(dest) != len(s.values) {
+		return fmt.Errorf("expected %v destination arguments in Scan, not %v", len(s.values), len(dest))
+	}
+	for i, value := range s.values {
+		if err := convertAssign(dest[i], value); err != nil {
+			return fmt.Errorf("scanning column %v: %w", s.columns[i], err)
+		}
+	}
+	return nil
+}
+
+// NextBatch returns the values of the next rows, up to the batch size of the stream.
+// It returns an empty batch once there are no more rows, and the error of the stream if one occurred.
+func (s *RowStream) NextBatch() (batch [][]interface{}, err error) {
+	batch = make([][]interface{}, 0, s.batchSize)
+	for len(batch) < s.batchSize && s.Next() {
+		batch = append(batch, s.values)
+	}
+	return batch, s.Err()
+}
+
+// ForEachBatch calls fn with each batch of rows until there are no more rows or fn returns an error,
+// the stream is always closed when it returns
+func (s *RowStream) ForEachBatch(fn func(batch [][]interface{}) error) (err error) {
+	defer func() {
+		if closeErr := s.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	var batch [][]interface{}
+	for {
+		batch, err = s.NextBatch()
+		if err != nil || len(batch) == 0 {
+			return err
+		}
+		if err = fn(batch); err != nil {
+			return err
+		}
+	}
+}
+
+// Err returns the error which stopped the stream, if any
+func (s *RowStream) Err() error {
+	return s.err
+}
+
+// Close closes the rows and, with a cursor, its transaction. Closing a stream more than once has no effect.
+func (s *RowStream) Close() (err error) {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	defer func() {
+		if s.err != nil {
+			s.op.end(s.err)
+		} else {
+			s.op.end(err)
+		}
+	}()
+	if s.rows != nil {
+		err = s.rows.Close()
+	}
+	if s.tx != nil {
+		// the cursor is only read from, it is closed with its transaction
+		if rollbackErr := s.tx.Rollback(); err == nil && rollbackErr != sql.ErrTxDone {
+			err = rollbackErr
+		}
+	}
+	if s.cancel != nil {
+		s.cancel()
+	}
+	if err != nil {
+		return fmt.Errorf("closing stream: %w", err)
+	}
+	return nil
+}
+
+// ReadInto reads the remaining rows into do, batch by batch, and closes the stream.
+// The rows are read like with ReadDataObject: the Table of do holds the typed columns, its Columns and IntMatrix or FloatMatrix are also set,
+// and NULL values are handled according to opts.NullPolicy.
+func (s *RowStream) ReadInto(do *DataObject, opts *DataObjectOptions) error {
+	b, err := newTableBuilder(s.columnTypes, opts)
+	if err != nil {
+		_ = s.Close()
+		return err
+	}
+	err = s.ForEachBatch(func(batch [][]interface{}) error {
+		for _, row := range batch {
+			if err := b.add(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	table, err := b.table()
+	if err != nil {
+		return err
+	}
+	do.SetTable(table)
+	return nil
+}
+
+// fetch fetches the next rows from the cursor
+func (s *RowStream) fetch() (err error) {
+	s.fetched = 0
+	s.rows, err = s.tx.QueryContext(s.ctx, "FETCH FORWARD "+strconv.Itoa(s.batchSize)+" FROM "+s.cursor)
+	if err != nil {
+		return NewDatabaseError(fmt.Errorf("fetching rows from cursor: %w", err))
+	}
+	return nil
+}
+
+// fail records err and closes the stream, or records the error closing it if err is nil
+func (s *RowStream) fail(err error) {
+	if s.err == nil && err != nil {
+		s.err = NewDatabaseError(s.limits.timeoutError(s.ctx, s.timeoutSet, err))
+	}
+	closeErr := s.Close()
+	if s.err == nil && closeErr != nil {
+		s.err = NewDatabaseError(closeErr)
+	}
+}
+
+// convertAssign stores the driver value src into dest, with the conversions of sql.Rows.Scan
+func convertAssign(dest, src interface{}) error {
+	switch d := dest.(type) {
+	case *interface{}:
+		*d = src
+		return nil
+	case sql.Scanner:
+		return d.Scan(src)
+	}
+
+	// reuse the conversions of database/sql through a nullable wrapper of the destination type
+	switch d := dest.(type) {
+	case *int64:
+		var n sql.NullInt64
+		if err := n.Scan(src); err != nil || !n.Valid {
+			return nullOr(err)
+		}
+		*d = n.Int64
+	case *float64:
+		var n sql.NullFloat64
+		if err := n.Scan(src); err != nil || !n.Valid {
+			return nullOr(err)
+		}
+		*d = n.Float64
+	case *string:
+		var n sql.NullString
+		if err := n.Scan(src); err != nil || !n.Valid {
+			return nullOr(err)
+		}
+		*d = n.String
+	case *bool:
+		var n sql.NullBool
+		if err := n.Scan(src); err != nil || !n.Valid {
+			return nullOr(err)
+		}
+		*d = n.Bool
+	case *time.Time:
+		var n sql.NullTime
+		if err := n.Scan(src); err != nil || !n.Valid {
+			return nullOr(err)
+		}
+		*d = n.Time
+	case *int:
+		var n sql.NullInt64
+		if err := n.Scan(src); err != nil || !n.Valid {
+			return nullOr(err)
+		}
+		*d = int(n.Int64)
+	case *[]byte:
+		switch v := src.(type) {
+		case []byte:
+			*d = append([]byte(nil), v...)
+		case string:
+			*d = []byte(v)
+		case nil:
+			*d = nil
+		default:
+			return fmt.Errorf("unsupported conversion of %T to []byte", src)
+		}
+	default:
+		return fmt.Errorf("unsupported scan destination %T", dest)
+	}
+	return nil
+}
+
+// nullOr returns err, or an error about converting NULL if err is nil
+func nullOr(err error) error {
+	if err != nil {
+		return err
+	}
+	return errors.New("converting NULL to a non-nullable destination")
+}
@@ -0,0 +1,122 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStream(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 25)
+
+	stream, err := db.Stream(context.Background(), &sdk.StreamOptions{BatchSize: 10}, retrieveQuery)
+	require.NoError(t, err)
+	require.Equal(t, []string{"name", "age", "weight", "height"}, stream.Columns())
+	sizes := make([]int, 0)
+	require.NoError(t, stream.ForEachBatch(func(batch [][]interface{}) error {
+		sizes = append(sizes, len(batch))
+		require.Equal(t, name, batch[0][0])
+		return nil
+	}))
+	require.Equal(t, []int{10, 10, 5}, sizes)
+	require.False(t, stream.Next())
+
+	// rows can be scanned one at a time, and the stream closed early
+	stream, err = db.Stream(context.Background(), nil, "SELECT name, age FROM patients ORDER BY age")
+	require.NoError(t, err)
+	var actName string
+	var actAge int64
+	require.True(t, stream.Next())
+	require.NoError(t, stream.Scan(&actName, &actAge))
+	require.Equal(t, name, actName)
+	require.Equal(t, int64(age), actAge)
+	require.NoError(t, stream.Close())
+	require.False(t, stream.Next())
+	require.NoError(t, stream.Err())
+
+	// errors of the callback are returned
+	stream, err = db.Stream(context.Background(), nil, retrieveQuery)
+	require.NoError(t, err)
+	errStop := errors.New("stop")
+	require.ErrorIs(t, stream.ForEachBatch(func(batch [][]interface{}) error { return errStop }), errStop)
+}
+
+func TestStreamCancelled(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 5)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := db.Stream(ctx, &sdk.StreamOptions{BatchSize: 2}, retrieveQuery)
+	require.NoError(t, err)
+	require.True(t, stream.Next())
+	cancel()
+	for stream.Next() {
+	}
+	require.ErrorIs(t, stream.Err(), context.Canceled)
+	// the connection was released
+	require.Zero(t, db.Stats().InUse)
+}
+
+func TestStreamReadInto(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 3)
+
+	stream, err := db.Stream(context.Background(), &sdk.StreamOptions{BatchSize: 2}, retrieveToFloatMatrix)
+	require.NoError(t, err)
+	var do sdk.DataObject
+	require.NoError(t, stream.ReadInto(&do, nil))
+	require.Equal(t, []string{"weight", "height"}, do.Columns)
+	require.Equal(t, [][]float64{{weight, height}, {weight, height}, {weight, height}}, do.FloatMatrix)
+
+	stream, err = db.Stream(context.Background(), nil, "SELECT age FROM patients")
+	require.NoError(t, err)
+	require.NoError(t, stream.ReadInto(&do, nil))
+	require.Nil(t, do.FloatMatrix)
+	require.Equal(t, [][]int64{{age}, {age + 1}, {age + 2}}, do.IntMatrix)
+
+	// NULL values are rejected by default, or handled according to the NULL policy
+	_, err = db.Exec("INSERT INTO patients (name, age) VALUES (?, ?)", name, age)
+	require.NoError(t, err)
+	stream, err = db.Stream(context.Background(), nil, retrieveToFloatMatrix)
+	require.NoError(t, err)
+	require.EqualError(t, stream.ReadInto(&do, nil), "reading row 4: column weight is NULL")
+	stream, err = db.Stream(context.Background(), &sdk.StreamOptions{BatchSize: 2}, "SELECT name, age, weight FROM patients")
+	require.NoError(t, err)
+	require.NoError(t, stream.ReadInto(&do, &sdk.DataObjectOptions{NullPolicy: sdk.NullPolicyNaN}))
+	require.Equal(t, 4, do.Table.NumRows())
+	require.Equal(t, sdk.DataTypeString, do.Table.Column("name").Type)
+	require.Equal(t, sdk.DataTypeInt, do.Table.Column("age").Type)
+	require.True(t, do.Table.Column("weight").IsNull(3))
+	require.Nil(t, do.FloatMatrix)
+}
+
+func TestStreamTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 3)
+	db.EnableTracing(true)
+	stream, err := db.Stream(context.Background(), &sdk.StreamOptions{BatchSize: 2}, retrieveQuery)
+	require.NoError(t, err)
+	// the span of the stream only ends when the stream is closed
+	for _, span := range recorder.Ended() {
+		require.NotEqual(t, "SELECT test", span.Name())
+	}
+	require.NoError(t, stream.ForEachBatch(func([][]interface{}) error { return nil }))
+	spans := recorder.Ended()
+	require.Equal(t, "SELECT test", spans[len(spans)-1].Name())
+	require.NotEqual(t, codes.Error, spans[len(spans)-1].Status().Code)
+
+	_, err = db.Stream(context.Background(), nil, "SELECT * FROM unknown")
+	require.Error(t, err)
+	spans = recorder.Ended()
+	require.Equal(t, "SELECT test", spans[len(spans)-1].Name())
+	require.Equal(t, codes.Error, spans[len(spans)-1].Status().Code)
+}
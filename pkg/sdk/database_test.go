@@ -1,10 +1,18 @@
 package sdk_test
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
+	"github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
 	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk/credentials"
 )
 
 const (
@@ -21,6 +29,28 @@ const (
 	sleepingTimeBetweenAttempts = 2
 )
 
+// newTestDatabase returns a SQLite database whose patients table holds the given number of rows, managed by a DBManager configured by conf
+// which is shut down at the end of the test. The database is stored in a temporary directory and named "test" unless dbConf says otherwise.
+func newTestDatabase(t *testing.T, conf sdk.DBManagerConfig, dbConf sdk.SQLiteConfig, rows int) *sdk.Database {
+	manager := sdk.NewDBManager(conf)
+	t.Cleanup(func() { require.NoError(t, manager.Shutdown()) })
+	if dbConf.Directory == "" {
+		dbConf.Directory = t.TempDir()
+	}
+	if dbConf.Database == "" {
+		dbConf.Database = "test"
+	}
+	db, err := manager.NewDatabase(dbConf)
+	require.NoError(t, err)
+	_, err = db.Exec(createQuery)
+	require.NoError(t, err)
+	for i := 0; i < rows; i++ {
+		_, err = db.Exec(insertQuery, name, age+i, weight, height)
+		require.NoError(t, err)
+	}
+	return db
+}
+
 func TestSQLite(t *testing.T) {
 	manager := sdk.NewDBManager(sdk.DBManagerConfig{
 		MaxConnectionAttempts:              maxConnAttempts,
@@ -53,3 +83,313 @@ func TestSQLite(t *testing.T) {
 	require.Equal(t, weight, actWeight)
 	require.Equal(t, height, actHeight)
 }
+
+func TestSQLiteCancelledContext(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{
+		MaxConnectionAttempts:              maxConnAttempts,
+		SleepingTimeBetweenAttemptsSeconds: sleepingTimeBetweenAttempts,
+	}, sdk.SQLiteConfig{}, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := db.StoreContext(ctx, insertQuery, name, age, weight, height)
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = db.RetrieveContext(ctx, retrieveQuery)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWaitReadyDeadline(t *testing.T) {
+	dbConf := sdk.PostgresConfig{
+		Host:     "127.0.0.1",
+		Port:     1,
+		Database: "test",
+		User:     "test",
+		Password: "test",
+	}
+	conn, err := sdk.NewConnection(dbConf)
+	require.NoError(t, err)
+
+	// without honouring the deadline this would block for 10 attempts * 10 seconds
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = sdk.NewDatabaseContext(ctx, dbConf, conn, 10, 10*time.Second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestSQLiteTransaction(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{
+		MaxConnectionAttempts:              maxConnAttempts,
+		SleepingTimeBetweenAttemptsSeconds: sleepingTimeBetweenAttempts,
+	}, sdk.SQLiteConfig{}, 0)
+
+	countPatients := func() (count int) {
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM patients").Scan(&count))
+		return
+	}
+
+	// a failing transaction is rolled back
+	errFailing := errors.New("failing transaction")
+	err := db.WithTx(context.Background(), nil, func(tx *sdk.Tx) error {
+		_, err := tx.Exec(insertQuery, name, age, weight, height)
+		require.NoError(t, err)
+		return errFailing
+	})
+	require.ErrorIs(t, err, errFailing)
+	require.Equal(t, 0, countPatients())
+
+	// a busy transaction is retried and eventually committed
+	attempts := 0
+	err = db.WithTx(context.Background(), &sdk.TxOptions{MaxRetries: 2}, func(tx *sdk.Tx) error {
+		attempts++
+		for i := 0; i < 2; i++ {
+			_, err := tx.Exec(insertQuery, name, age, weight, height)
+			require.NoError(t, err)
+		}
+		if attempts == 1 {
+			return sqlite3.Error{Code: sqlite3.ErrBusy}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+	require.Equal(t, 2, countPatients())
+
+	// options without retry count retry DefaultTxMaxRetries times
+	attempts = 0
+	err = db.WithTx(context.Background(), &sdk.TxOptions{Isolation: sql.LevelSerializable}, func(tx *sdk.Tx) error {
+		attempts++
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	})
+	require.Error(t, err)
+	require.Equal(t, sdk.DefaultTxMaxRetries+1, attempts)
+
+	// retries are disabled with a negative MaxRetries
+	attempts = 0
+	err = db.WithTx(context.Background(), &sdk.TxOptions{MaxRetries: -1}, func(tx *sdk.Tx) error {
+		attempts++
+		return sqlite3.Error{Code: sqlite3.ErrBusy}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+}
+
+func TestDatabaseLeases(t *testing.T) {
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{})
+	dbConf := sdk.SQLiteConfig{
+		Directory: t.TempDir(),
+		Database:  "test",
+	}
+
+	first, err := manager.Acquire(dbConf)
+	require.NoError(t, err)
+	second, err := manager.Acquire(dbConf)
+	require.NoError(t, err)
+	require.Same(t, first.Database, second.Database)
+	require.Len(t, manager.Leases(), 2)
+
+	// closing the config or releasing one lease does not close the connection used by the other
+	require.NoError(t, manager.Close(dbConf))
+	require.NoError(t, first.Release())
+	require.NoError(t, first.Release())
+	require.NoError(t, second.Ping())
+	require.Len(t, manager.Leases(), 1)
+
+	// releasing the last lease closes the connection
+	require.NoError(t, second.Close())
+	require.Error(t, second.Ping())
+	require.Empty(t, manager.Leases())
+
+	// a database obtained with GetDatabase is kept open after its leases are released
+	db, err := manager.GetDatabase(dbConf)
+	require.NoError(t, err)
+	lease, err := manager.Acquire(dbConf)
+	require.NoError(t, err)
+	require.NoError(t, lease.Release())
+	require.NoError(t, db.Ping())
+
+	// creating the same database again, or after acquiring it, returns the registered one
+	lease, err = manager.Acquire(dbConf)
+	require.NoError(t, err)
+	again, err := manager.NewDatabase(dbConf)
+	require.NoError(t, err)
+	require.Same(t, db, again)
+	require.Same(t, db, lease.Database)
+	require.Empty(t, manager.Leases()[0].Stack)
+
+	// a lease garbage collected without having been released does not close its database
+	leased := lease.Database
+	lease = nil
+	runtime.GC()
+	runtime.GC()
+	require.NoError(t, leased.Ping())
+	require.NoError(t, manager.CloseAll())
+
+	// stack traces of the leases are only recorded if enabled
+	manager = sdk.NewDBManager(sdk.DBManagerConfig{LeaseStackTraces: true})
+	lease, err = manager.Acquire(dbConf)
+	require.NoError(t, err)
+	require.Contains(t, manager.Leases()[0].Stack, "TestDatabaseLeases")
+	require.NoError(t, lease.Release())
+	require.NoError(t, manager.Shutdown())
+}
+
+func TestSharedDatabase(t *testing.T) {
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{})
+	defer manager.Shutdown()
+	dbConf := sdk.SQLiteConfig{
+		Directory: t.TempDir(),
+		Database:  "test",
+	}
+
+	// two data sources using the same database
+	first, err := manager.GetDatabase(dbConf)
+	require.NoError(t, err)
+	second, err := manager.GetDatabase(dbConf)
+	require.NoError(t, err)
+	require.Same(t, first, second)
+
+	// the first data source closing does not close the database used by the second one
+	require.NoError(t, manager.Close(dbConf))
+	require.NoError(t, second.Ping())
+	_, err = second.Exec(createQuery)
+	require.NoError(t, err)
+
+	// the database is closed once the second data source closes it
+	require.NoError(t, manager.Close(dbConf))
+	require.Error(t, second.Ping())
+	require.Empty(t, manager.Status())
+}
+
+func TestPoolAndIdleEviction(t *testing.T) {
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{
+		PoolConfig:         sdk.PoolConfig{MaxOpenConns: 4, MaxIdleConns: 2},
+		IdleTimeoutSeconds: 3600,
+	})
+	dbConf := sdk.SQLiteConfig{
+		Directory:  t.TempDir(),
+		Database:   "test",
+		PoolConfig: sdk.PoolConfig{MaxOpenConns: 8},
+	}
+	leasedConf := sdk.SQLiteConfig{Directory: t.TempDir(), Database: "leased"}
+
+	db, err := manager.GetDatabase(dbConf)
+	require.NoError(t, err)
+	require.Equal(t, 8, db.Stats().MaxOpenConnections)
+
+	// a leased database is not evicted, and is kept open once released until it is evicted
+	lease, err := manager.Acquire(leasedConf)
+	require.NoError(t, err)
+	require.Equal(t, 0, manager.EvictIdle(0))
+	leased := lease.Database
+	require.NoError(t, lease.Release())
+	require.NoError(t, leased.Ping())
+	require.Equal(t, 0, manager.EvictIdle(time.Hour))
+
+	// an idle database without leases is closed and transparently reopened,
+	// while a database obtained with GetDatabase is never evicted
+	require.Equal(t, 1, manager.EvictIdle(0))
+	require.Error(t, leased.Ping())
+	require.NoError(t, db.Ping())
+	lease, err = manager.Acquire(leasedConf)
+	require.NoError(t, err)
+	require.NotSame(t, leased, lease.Database)
+	require.NoError(t, lease.Ping())
+	require.NoError(t, lease.Release())
+
+	// configurations of the same database with different pool settings do not share their connection pool
+	small := dbConf
+	small.PoolConfig = sdk.PoolConfig{MaxOpenConns: 1}
+	smallDB, err := manager.GetDatabase(small)
+	require.NoError(t, err)
+	require.NotSame(t, db, smallDB)
+	require.Equal(t, 1, smallDB.Stats().MaxOpenConnections)
+	require.Equal(t, 8, db.Stats().MaxOpenConnections)
+	require.NoError(t, manager.Shutdown())
+}
+
+func TestHealthCheck(t *testing.T) {
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{HealthDownAfterFailures: 2})
+	dbConf := sdk.SQLiteConfig{
+		Directory: t.TempDir(),
+		Database:  "test",
+	}
+	db, err := manager.GetDatabase(dbConf)
+	require.NoError(t, err)
+
+	status := manager.Status()
+	require.Len(t, status, 1)
+	require.Equal(t, sdk.HealthUnknown, status[0].State)
+
+	changes, unsubscribe := manager.Subscribe()
+	defer unsubscribe()
+
+	manager.CheckHealth(context.Background())
+	change := <-changes
+	require.Equal(t, sdk.HealthUnknown, change.Previous)
+	require.Equal(t, sdk.HealthHealthy, change.Status.State)
+	require.Equal(t, "test", change.Status.Database)
+	require.False(t, change.Status.LastSuccess.IsZero())
+
+	// a failing database is first degraded, then down
+	require.NoError(t, db.DB.Close())
+	manager.CheckHealth(context.Background())
+	require.Equal(t, sdk.HealthDegraded, (<-changes).Status.State)
+	manager.CheckHealth(context.Background())
+	change = <-changes
+	require.Equal(t, sdk.HealthDown, change.Status.State)
+	require.Error(t, change.Status.LastError)
+	require.Equal(t, 2, manager.Status()[0].ConsecutiveFailures)
+
+	// a single failure right after startup only degrades the database
+	require.NoError(t, manager.Shutdown())
+	manager = sdk.NewDBManager(sdk.DBManagerConfig{HealthDownAfterFailures: 2})
+	defer manager.Shutdown()
+	db, err = manager.GetDatabase(dbConf)
+	require.NoError(t, err)
+	require.NoError(t, db.DB.Close())
+	manager.CheckHealth(context.Background())
+	status = manager.Status()
+	require.Equal(t, sdk.HealthDegraded, status[0].State)
+	require.Equal(t, 1, status[0].ConsecutiveFailures)
+}
+
+// credentialedSQLiteConfig resolves the name of its database file from the username of its credentials
+type credentialedSQLiteConfig struct {
+	sdk.SQLiteConfig
+	credentialsID string
+}
+
+func (conf credentialedSQLiteConfig) GetCredentialsID() string {
+	return conf.credentialsID
+}
+
+func (conf credentialedSQLiteConfig) WithCredentials(creds *credentials.Credentials) sdk.DatabaseConfig {
+	conf.SQLiteConfig.Database = creds.Username()
+	return conf
+}
+
+func TestCredentialsResolution(t *testing.T) {
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{})
+	dir := t.TempDir()
+	dbConf := credentialedSQLiteConfig{
+		SQLiteConfig:  sdk.SQLiteConfig{Directory: dir, Database: "unresolved"},
+		credentialsID: "sqlite-creds",
+	}
+
+	// credentials cannot be resolved without provider
+	_, err := manager.NewDatabase(dbConf)
+	require.Error(t, err)
+
+	manager.SetCredentialsProvider(credentials.NewLocal(map[string]*credentials.Credentials{
+		"sqlite-creds": credentials.NewCredentials("resolved", "", ""),
+	}))
+	db, err := manager.NewDatabase(dbConf)
+	require.NoError(t, err)
+	_, err = db.Exec(createQuery)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(dir, "resolved.db"))
+	require.NoFileExists(t, filepath.Join(dir, "unresolved.db"))
+	require.NoError(t, manager.CloseAll())
+}
@@ -0,0 +1,209 @@
+package sdk
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracerName is the name of the OpenTelemetry tracer creating the spans of the Database operations
+const TracerName = "github.com/tuneinsight/sdk-datasource/pkg/sdk"
+
+// Attributes of the Database spans which are not part of the OpenTelemetry semantic conventions
+const (
+	// rowsAffectedKey is the number of records written by a statement
+	rowsAffectedKey = attribute.Key("db.rows_affected")
+	// errorCategoryKey is the ErrorCategory of a failed operation
+	errorCategoryKey = attribute.Key("db.error.category")
+	// retryAttemptKey and retryBackOffKey are the number of the failed attempt and the waiting time before the next one of a retry event
+	retryAttemptKey = attribute.Key("retry.attempt")
+	retryBackOffKey = attribute.Key("retry.backoff_ms")
+)
+
+// dbSpanKey is the context key of the span of the ongoing Database operation
+type dbSpanKey struct{}
+
+// EnableTracing makes the operations of the Database (Store, Update, Retrieve, Delete, Stream, WaitReady and their variants) create spans
+// with the global OpenTelemetry tracer provider, as children of the span of their context. false disables tracing.
+// The spans follow the database semantic conventions, statements are recorded without their literal values.
+func (db *Database) EnableTracing(enabled bool) {
+	db.tracing.Store(enabled)
+}
+
+// startSpan starts the span of a Database operation running statement, if any.
+// If tracing is disabled, ctx is returned with a span doing nothing.
+func (db *Database) startSpan(ctx context.Context, operation string, statement string) (context.Context, trace.Span) {
+	if !db.tracing.Load() {
+		return ctx, trace.SpanFromContext(context.Background())
+	}
+	attributes := []attribute.KeyValue{
+		dbSystem(db.DriverName()),
+		semconv.DBNameKey.String(db.Name()),
+		semconv.CodeFunctionKey.String(operation),
+	}
+	name := operation
+	if statement != "" {
+		attributes = append(attributes, semconv.DBStatementKey.String(sanitizeStatement(statement, db.Dialect().Name())))
+		if keyword := statementOperation(statement); keyword != "" {
+			attributes = append(attributes, semconv.DBOperationKey.String(keyword))
+			name = keyword
+		}
+	}
+	ctx, span := otel.Tracer(TracerName).Start(ctx, name+" "+db.Name(),
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+	return context.WithValue(ctx, dbSpanKey{}, span), span
+}
+
+// endSpan records the error of the operation of span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(errorCategoryKey.String(string(ErrorCategoryOf(err))))
+	}
+	span.End()
+}
+
+// recordRowsAffected records the number of records written by the statement of the Database operation of ctx, if it is traced
+func recordRowsAffected(ctx context.Context, affected int64) {
+	span, ok := ctx.Value(dbSpanKey{}).(trace.Span)
+	if ok && span == trace.SpanFromContext(ctx) {
+		span.SetAttributes(rowsAffectedKey.Int64(affected))
+	}
+}
+
+// recordRetry records a failed attempt of the operation of span which is retried after backOff
+func recordRetry(span trace.Span, attempt int, backOff time.Duration, err error) {
+	span.AddEvent("retry", trace.WithAttributes(
+		retryAttemptKey.Int(attempt),
+		retryBackOffKey.Int64(backOff.Milliseconds()),
+		semconv.ExceptionMessageKey.String(err.Error()),
+	))
+}
+
+// dbSystem returns the db.system attribute of a driver
+func dbSystem(driverName string) attribute.KeyValue {
+	switch driverName {
+	case "postgres":
+		return semconv.DBSystemPostgreSQL
+	case "mysql":
+		return semconv.DBSystemMySQL
+	case "sqlite3":
+		return semconv.DBSystemSqlite
+	}
+	return semconv.DBSystemOtherSQL
+}
+
+// statementOperation returns the first keyword of a statement in upper case, e.g. SELECT or INSERT
+func statementOperation(statement string) string {
+	statement = strings.TrimSpace(statement)
+	end := strings.IndexFunc(statement, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end < 0 {
+		end = len(statement)
+	}
+	return strings.ToUpper(statement[:end])
+}
+
+// sanitizeStatement returns statement with its string and numeric literals replaced by "?", so that the values they may contain are not recorded.
+// Placeholders and quoted identifiers are left unchanged. Backslashes escape quotes in string literals, so that no part of
+// a MySQL string literal is kept, at the cost of also hiding what follows a postgres string literal ending with a backslash.
+// Double-quoted strings are literals on MySQL, and dollar-quoted strings (e.g. $$text$$ or $tag$text$tag$) on postgres.
+func sanitizeStatement(statement string, dialect string) string {
+	return maskLiterals(statement, dialect, false)
+}
+
+// maskLiterals returns statement with its string and numeric literals replaced by "?", and also its quoted identifiers if identifiers is set,
+// so that what remains is only made of keywords, unquoted identifiers, placeholders and operators. dialect is the name of the Dialect of the statement.
+func maskLiterals(statement string, dialect string, identifiers bool) string {
+	var b strings.Builder
+	b.Grow(len(statement))
+	for i := 0; i < len(statement); {
+		c := statement[i]
+		switch {
+		case c == '\'' || c == '"' && dialect == "mysql":
+			b.WriteByte('?')
+			i = quotedStringEnd(statement, i)
+		case c == '$' && dialect == "postgres" && (i == 0 || !isIdentifierByte(statement[i-1])) && dollarQuoteTag(statement[i:]) != "":
+			tag := dollarQuoteTag(statement[i:])
+			end := strings.Index(statement[i+len(tag):], tag)
+			if end < 0 {
+				end = len(statement) - i - 2*len(tag)
+			}
+			b.WriteByte('?')
+			i += end + 2*len(tag)
+		case c == '"' || c == '`':
+			end := strings.IndexByte(statement[i+1:], c)
+			if end < 0 {
+				end = len(statement) - i - 2
+			}
+			if identifiers {
+				b.WriteByte('?')
+			} else {
+				b.WriteString(statement[i : i+end+2])
+			}
+			i += end + 2
+		case isDigit(c) && (i == 0 || !isIdentifierByte(statement[i-1])):
+			j := i
+			for j < len(statement) && (isDigit(statement[j]) || statement[j] == '.') {
+				j++
+			}
+			b.WriteByte('?')
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// quotedStringEnd returns the index following the string literal starting with the quote at statement[start],
+// quotes being escaped by doubling them or with a backslash
+func quotedStringEnd(statement string, start int) int {
+	quote := statement[start]
+	j := start + 1
+	for ; j < len(statement); j++ {
+		if statement[j] == '\\' {
+			j++
+		} else if statement[j] == quote {
+			if j+1 < len(statement) && statement[j+1] == quote {
+				j++
+				continue
+			}
+			break
+		}
+	}
+	return j + 1
+}
+
+// dollarQuoteTag returns the opening tag of the postgres dollar-quoted string starting s, e.g. "$$" or "$tag$", or "" if s does not start with one.
+// Placeholders such as $1 are not tags since tags cannot start with a digit.
+func dollarQuoteTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		switch c := s[j]; {
+		case c == '$':
+			return s[:j+1]
+		case isDigit(c) && j == 1, !isIdentifierByte(c):
+			return ""
+		}
+	}
+	return ""
+}
+
+// isDigit returns whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentifierByte returns whether c can be part of an unquoted identifier or of a placeholder such as $1
+func isIdentifierByte(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c >= 0x80
+}
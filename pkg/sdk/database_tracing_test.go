@@ -0,0 +1,115 @@
+package sdk_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestDatabaseTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	db := newTestDatabase(t, sdk.DBManagerConfig{Tracing: true}, sdk.SQLiteConfig{}, 0)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+	_, err := db.StoreResultContext(ctx, "INSERT INTO patients (name, age, weight, height) VALUES ('it''s secret', ?, 80.5, ?)", age, height)
+	require.NoError(t, err)
+	err = db.DeleteContext(ctx, "DELETE FROM patients WHERE name = ? RETURNING name", "unknown")
+	require.Error(t, err)
+	parent.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 5)
+	waitReady, store, deleteSpan := spans[0], spans[1], spans[3]
+	require.Equal(t, "WaitReady test", waitReady.Name())
+	require.Equal(t, store.SpanContext().SpanID(), waitReady.Parent().SpanID())
+
+	require.Equal(t, "INSERT test", store.Name())
+	require.Equal(t, parent.SpanContext().SpanID(), store.Parent().SpanID())
+	attributes := spanAttributes(store)
+	require.Equal(t, "sqlite", attributes["db.system"].AsString())
+	require.Equal(t, "test", attributes["db.name"].AsString())
+	require.Equal(t, "INSERT", attributes["db.operation"].AsString())
+	require.Equal(t, "INSERT INTO patients (name, age, weight, height) VALUES (?, ?, ?, ?)", attributes["db.statement"].AsString())
+	require.Equal(t, int64(1), attributes["db.rows_affected"].AsInt64())
+
+	require.Equal(t, "DELETE test", deleteSpan.Name())
+	require.Equal(t, codes.Error, deleteSpan.Status().Code)
+	require.Equal(t, string(sdk.ErrorCategoryNotFound), spanAttributes(deleteSpan)["db.error.category"].AsString())
+
+	// failed connection attempts are recorded as retry events
+	require.NoError(t, db.DB.Close())
+	db.RetryPolicy = sdk.ConstantBackOff{Interval: time.Millisecond, MaxRetries: 2}
+	require.Error(t, db.WaitReady())
+	spans = recorder.Ended()
+	waitReady = spans[len(spans)-1]
+	require.Equal(t, codes.Error, waitReady.Status().Code)
+	require.Len(t, waitReady.Events(), 3)
+	require.Equal(t, "retry", waitReady.Events()[0].Name)
+	require.Equal(t, "exception", waitReady.Events()[2].Name)
+
+	// no spans are created once tracing is disabled
+	db.EnableTracing(false)
+	_ = db.WaitReady()
+	require.Len(t, recorder.Ended(), len(spans))
+}
+
+// dialectSQLiteConfig is a SQLite configuration with the Dialect of another database
+type dialectSQLiteConfig struct {
+	sdk.SQLiteConfig
+	dialect sdk.Dialect
+}
+
+func (conf dialectSQLiteConfig) Dialect() sdk.Dialect {
+	return conf.dialect
+}
+
+func TestDatabaseTracingLiterals(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	manager := sdk.NewDBManager(sdk.DBManagerConfig{Tracing: true})
+	defer manager.Shutdown()
+	for _, tc := range []struct {
+		dialect   sdk.Dialect
+		statement string
+		sanitized string
+	}{
+		{sdk.PostgresDialect{}, `SELECT $$secret$$, $tag$it's $$ secret$tag$, "column", $1`, `SELECT ?, ?, "column", $1`},
+		{sdk.PostgresDialect{}, `SELECT $unterminated$ secret`, `SELECT ?`},
+		{sdk.MySQLDialect{}, `SELECT "secret", "it\"s ""secret""", 'other', ` + "`column`", "SELECT ?, ?, ?, `column`"},
+		{sdk.SQLiteDialect{}, `SELECT "column", $$1`, `SELECT "column", $$1`},
+	} {
+		conf := dialectSQLiteConfig{SQLiteConfig: sdk.SQLiteConfig{Directory: t.TempDir(), Database: "test"}, dialect: tc.dialect}
+		db, err := manager.NewDatabase(conf)
+		require.NoError(t, err)
+		rows, err := db.Retrieve(tc.statement, 1)
+		if err == nil {
+			require.NoError(t, rows.Close())
+		}
+		spans := recorder.Ended()
+		require.Equal(t, tc.sanitized, spanAttributes(spans[len(spans)-1])["db.statement"].AsString(), tc.statement)
+	}
+}
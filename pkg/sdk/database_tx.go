@@ -0,0 +1,177 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultTxMaxRetries is the number of times a transaction is retried by WithTx unless TxOptions.MaxRetries is set
+	DefaultTxMaxRetries = 3
+	// txRetryBaseInterval is the waiting time before the first retry of a transaction, doubled at each retry
+	txRetryBaseInterval = 50 * time.Millisecond
+	// txRetryMaxInterval caps the waiting time between two retries of a transaction
+	txRetryMaxInterval = 2 * time.Second
+)
+
+// TxOptions holds the options of a transaction run with Database.WithTx
+type TxOptions struct {
+	// Isolation is the isolation level of the transaction, the driver's default level is used if left to sql.LevelDefault
+	Isolation sql.IsolationLevel
+	// ReadOnly marks the transaction as read-only
+	ReadOnly bool
+	// MaxRetries is the number of times the transaction is retried after a serialization failure or a deadlock,
+	// DefaultTxMaxRetries if 0. A negative value disables retries.
+	MaxRetries int
+}
+
+// Tx is a database transaction, it exposes the same helpers as Database which are executed as part of the transaction
+type Tx struct {
+	*sql.Tx
+	db  *Database
+	ctx context.Context
+}
+
+// WithTx runs fn inside a transaction which is committed if fn returns nil and rolled back otherwise.
+// If the transaction fails because of a serialization failure or a deadlock (or SQLITE_BUSY on SQLite),
+// it is retried as a whole up to opts.MaxRetries times, fn must therefore be safe to call several times.
+// If opts is nil, the default isolation level is used and the transaction is retried up to DefaultTxMaxRetries times.
+func (db *Database) WithTx(ctx context.Context, opts *TxOptions, fn func(tx *Tx) error) (err error) {
+	if opts == nil {
+		opts = &TxOptions{}
+	}
+	maxRetries := opts.MaxRetries
+	if maxRetries == 0 {
+		maxRetries = DefaultTxMaxRetries
+	}
+
+	err = db.WaitReadyContext(ctx)
+	if err != nil {
+		return
+	}
+
+	for attempt := 0; ; attempt++ {
+		err = db.runTx(ctx, opts, fn)
+		if err == nil || attempt >= maxRetries || ctx.Err() != nil || !isRetryableTxError(err) {
+			return err
+		}
+
+		backoff := txRetryBackoff(attempt)
+		db.Warnf("transaction on DB %v failed, retrying in %v: %v", db.Name(), backoff, err)
+		if sleepErr := sleepContext(ctx, backoff); sleepErr != nil {
+			return fmt.Errorf("retrying transaction: %w", sleepErr)
+		}
+	}
+}
+
+// runTx runs fn in a single transaction attempt
+func (db *Database) runTx(ctx context.Context, opts *TxOptions, fn func(tx *Tx) error) (err error) {
+	sqlTx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: opts.Isolation, ReadOnly: opts.ReadOnly})
+	if err != nil {
+		return NewDatabaseError(fmt.Errorf("beginning transaction: %w", err))
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	err = fn(&Tx{Tx: sqlTx, db: db, ctx: ctx})
+	if err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+			return fmt.Errorf("%w (rolling back transaction: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	err = sqlTx.Commit()
+	if err != nil {
+		return NewDatabaseError(fmt.Errorf("committing transaction: %w", err))
+	}
+	return nil
+}
+
+// Store stores records as part of the transaction.
+// sqlStatement must be in the form of "INSERT INTO xxx VALUES ($1, $2, ...) RETURNING id".
+func (tx *Tx) Store(sqlStatement string, args ...interface{}) (id string, err error) {
+	return tx.StoreContext(tx.ctx, sqlStatement, args...)
+}
+
+// StoreContext stores records as part of the transaction, the statement is cancelled if ctx is done.
+func (tx *Tx) StoreContext(ctx context.Context, sqlStatement string, args ...interface{}) (id string, err error) {
+	ctx, op := tx.db.startOperation(ctx, "Store", sqlStatement)
+	defer func() { op.end(err) }()
+	return store(ctx, tx.Tx, tx.db.Dialect().SupportsReturning(), sqlStatement, args...)
+}
+
+// Update updates records as part of the transaction.
+// sqlStatement must be in the form of "UPDATE xxx SET xxx WHERE xxx RETURNING id".
+func (tx *Tx) Update(sqlStatement string, args ...interface{}) (id string, err error) {
+	return tx.UpdateContext(tx.ctx, sqlStatement, args...)
+}
+
+// UpdateContext updates records as part of the transaction, the statement is cancelled if ctx is done.
+func (tx *Tx) UpdateContext(ctx context.Context, sqlStatement string, args ...interface{}) (id string, err error) {
+	ctx, op := tx.db.startOperation(ctx, "Update", sqlStatement)
+	defer func() { op.end(err) }()
+	id, err = update(ctx, tx.Tx, tx.db.Dialect().SupportsReturning(), sqlStatement, args...)
+	if err != nil {
+		return
+	}
+	tx.db.Debugf("correctly updated record with ID: %v", id)
+	return
+}
+
+// Retrieve retrieves records as part of the transaction, the rows must be closed before the transaction ends.
+// sqlStatement must be in the form of "SELECT xxx FROM xxx [WHERE xxx]".
+func (tx *Tx) Retrieve(sqlStatement string, args ...interface{}) (rows *sql.Rows, err error) {
+	return tx.RetrieveContext(tx.ctx, sqlStatement, args...)
+}
+
+// RetrieveContext retrieves records as part of the transaction, the returned rows are closed if ctx is done.
+func (tx *Tx) RetrieveContext(ctx context.Context, sqlStatement string, args ...interface{}) (rows *sql.Rows, err error) {
+	ctx, op := tx.db.startOperation(ctx, "Retrieve", sqlStatement)
+	defer func() { op.end(err) }()
+	return retrieve(ctx, tx.Tx, sqlStatement, args...)
+}
+
+// Delete deletes records as part of the transaction.
+// sqlStatement must be in the form of "DELETE FROM xxx WHERE xxx RETURN id".
+func (tx *Tx) Delete(sqlStatement string, args ...interface{}) (err error) {
+	return tx.DeleteContext(tx.ctx, sqlStatement, args...)
+}
+
+// DeleteContext deletes records as part of the transaction, the statement is cancelled if ctx is done.
+func (tx *Tx) DeleteContext(ctx context.Context, sqlStatement string, args ...interface{}) (err error) {
+	ctx, op := tx.db.startOperation(ctx, "Delete", sqlStatement)
+	defer func() { op.end(err) }()
+	return del(ctx, tx.Tx, tx.db.Dialect().SupportsReturning(), sqlStatement, args...)
+}
+
+// Context returns the context the transaction was started with
+func (tx *Tx) Context() context.Context {
+	return tx.ctx
+}
+
+// isRetryableTxError returns whether a failed transaction can be retried as a whole: serialization failures and deadlocks,
+// or busy and locked databases on SQLite (see ErrorCategorySerialization)
+func isRetryableTxError(err error) bool {
+	return ErrorCategoryOf(err) == ErrorCategorySerialization
+}
+
+// txRetryBackoff returns the waiting time before retrying a transaction for the (attempt+1)-th time
+func txRetryBackoff(attempt int) time.Duration {
+	backoff := txRetryBaseInterval
+	for i := 0; i < attempt && backoff < txRetryMaxInterval; i++ {
+		backoff *= 2
+	}
+	if backoff > txRetryMaxInterval {
+		backoff = txRetryMaxInterval
+	}
+	return backoff
+}
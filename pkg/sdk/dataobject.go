@@ -10,10 +10,10 @@ type DataObject struct {
 	// SharedID is the DataObjectSharedID that this DataObject should have.
 	SharedID models.DataObjectSharedID
 
-	// TODO: the DataObjet defines at the moment int or float values, vectors or matrices,
-	// TODO: but it should be integrated with the definition and implementation of the datamanager's DataObject to be generic
+	// Table holds typed columns with NULL values, see SetTable to also fill Columns and the matrices for the plugins reading them
+	Table *DataTable
 
-	// the ones not being used should be left to nil
+	// the ones not being used should be left to nil, AsTable converts IntMatrix or FloatMatrix to a Table
 	IntValue  *int64
 	IntVector []int64
 	IntMatrix [][]int64
@@ -0,0 +1,278 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// MetadataSQLType is the DataColumn metadata holding the database type of the columns read from sql.Rows, e.g. "VARCHAR" or "INT4"
+const MetadataSQLType = "sql-type"
+
+// NullPolicy defines how the NULL values of sql.Rows are stored in a DataObject
+type NullPolicy string
+
+const (
+	// NullPolicyError fails reading rows with NULL values, it is the default policy
+	NullPolicyError NullPolicy = "error"
+	// NullPolicyDropRow skips the rows with NULL values
+	NullPolicyDropRow NullPolicy = "drop-row"
+	// NullPolicySentinel stores DataObjectOptions.Sentinel in place of the NULL values of integer and float columns,
+	// which are then not marked as NULL. The NULL values of the other columns are kept.
+	NullPolicySentinel NullPolicy = "sentinel"
+	// NullPolicyNaN keeps the NULL values, which become NaN in FloatMatrix. The values of float columns are also set to NaN.
+	NullPolicyNaN NullPolicy = "nan"
+)
+
+// DataObjectOptions are the options of reading sql.Rows into a DataObject
+type DataObjectOptions struct {
+	NullPolicy NullPolicy
+	// Sentinel is the value replacing NULL values with NullPolicySentinel, truncated for integer columns
+	Sentinel float64
+	// Types forces the type of the columns with the given names, e.g. DataTypeCategorical, instead of the one chosen from their database type
+	Types map[string]DataType
+}
+
+// ReadDataObject reads all the rows into a DataObject and closes them. The type of each column is chosen from its scan type and its database type
+// (see sql.ColumnType), or else from its first non-NULL value, and NULL values are handled according to opts.NullPolicy.
+// The Table of the returned DataObject holds the typed columns, and its Columns and IntMatrix or FloatMatrix are also filled, see DataObject.SetTable.
+func ReadDataObject(rows *sql.Rows, opts *DataObjectOptions) (do *DataObject, err error) {
+	defer func() {
+		if closeErr := rows.Close(); err == nil && closeErr != nil {
+			err = NewDatabaseError(fmt.Errorf("closing rows: %w", closeErr))
+		}
+	}()
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("getting column types: %w", err))
+	}
+	b, err := newTableBuilder(columnTypes, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	row := make([]interface{}, len(columnTypes))
+	dest := make([]interface{}, len(row))
+	for i := range row {
+		dest[i] = &row[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return nil, NewDatabaseError(fmt.Errorf("scanning row %v: %w", b.rows+1, err))
+		}
+		if err = b.add(row); err != nil {
+			return nil, err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, NewDatabaseError(fmt.Errorf("reading rows: %w", err))
+	}
+
+	table, err := b.table()
+	if err != nil {
+		return nil, err
+	}
+	do = new(DataObject)
+	do.SetTable(table)
+	return do, nil
+}
+
+// RetrieveDataObject runs a query on the Database and reads its rows into a DataObject, see ReadDataObject
+func (db *Database) RetrieveDataObject(ctx context.Context, opts *DataObjectOptions, sqlStatement string, args ...interface{}) (*DataObject, error) {
+	rows, err := db.RetrieveContext(ctx, sqlStatement, args...)
+	if err != nil {
+		return nil, err
+	}
+	return ReadDataObject(rows, opts)
+}
+
+// tableBuilder builds a DataTable from rows read one at a time, typing their columns and handling their NULL values as described by ReadDataObject
+type tableBuilder struct {
+	opts        *DataObjectOptions
+	columnTypes []*sql.ColumnType
+	columns     []*DataColumn
+	// pendingNulls holds the number of NULL values of the columns without database type, e.g. expressions on SQLite,
+	// which are only created from their first non-NULL value
+	pendingNulls map[int]int
+	// rows is the number of rows read, including the dropped ones
+	rows int
+}
+
+// newTableBuilder returns a tableBuilder of rows whose columns are of types columnTypes
+func newTableBuilder(columnTypes []*sql.ColumnType, opts *DataObjectOptions) (*tableBuilder, error) {
+	if opts == nil {
+		opts = &DataObjectOptions{}
+	}
+	switch opts.NullPolicy {
+	case "", NullPolicyError, NullPolicyDropRow, NullPolicySentinel, NullPolicyNaN:
+	default:
+		return nil, fmt.Errorf("unknown NULL policy %q", opts.NullPolicy)
+	}
+
+	b := &tableBuilder{opts: opts, columnTypes: columnTypes, columns: make([]*DataColumn, len(columnTypes)), pendingNulls: make(map[int]int)}
+	for i, ct := range columnTypes {
+		typ, ok := opts.Types[ct.Name()]
+		if !ok {
+			typ, ok = columnDataType(ct)
+		}
+		if !ok {
+			b.pendingNulls[i] = 0
+			continue
+		}
+		var err error
+		if b.columns[i], err = newSQLColumn(ct, typ); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// add appends the values of a row to the columns, unless the row is dropped
+func (b *tableBuilder) add(row []interface{}) (err error) {
+	b.rows++
+	for i, value := range row {
+		if value != nil {
+			continue
+		}
+		switch b.opts.NullPolicy {
+		case NullPolicyDropRow:
+			return nil
+		case NullPolicySentinel, NullPolicyNaN:
+		default:
+			return fmt.Errorf("reading row %v: column %v is NULL", b.rows, b.columnTypes[i].Name())
+		}
+	}
+
+	for i, value := range row {
+		if b.columns[i] == nil {
+			if value == nil {
+				b.pendingNulls[i]++
+				continue
+			}
+			if b.columns[i], err = newSQLColumn(b.columnTypes[i], valueDataType(value)); err != nil {
+				return err
+			}
+			for n := 0; n < b.pendingNulls[i]; n++ {
+				appendNull(b.columns[i], b.opts)
+			}
+			delete(b.pendingNulls, i)
+		}
+		if value == nil {
+			appendNull(b.columns[i], b.opts)
+		} else if err = b.columns[i].Append(value); err != nil {
+			return fmt.Errorf("reading row %v: %w", b.rows, err)
+		}
+	}
+	return nil
+}
+
+// table returns the table of the added rows
+func (b *tableBuilder) table() (*DataTable, error) {
+	// columns with only NULL values are float columns, which can be read in FloatMatrix
+	for i, nulls := range b.pendingNulls {
+		var err error
+		if b.columns[i], err = newSQLColumn(b.columnTypes[i], DataTypeFloat); err != nil {
+			return nil, err
+		}
+		for n := 0; n < nulls; n++ {
+			appendNull(b.columns[i], b.opts)
+		}
+		delete(b.pendingNulls, i)
+	}
+	return NewDataTable(b.columns...)
+}
+
+// integerTypeNames are the database type names of integer columns, without size nor sign, e.g. "INT" for "INT(11) UNSIGNED"
+var integerTypeNames = map[string]bool{
+	"INT": true, "INTEGER": true, "INT2": true, "INT4": true, "INT8": true,
+	"TINYINT": true, "SMALLINT": true, "MEDIUMINT": true, "BIGINT": true, "BIG INT": true,
+	"SERIAL": true, "SMALLSERIAL": true, "BIGSERIAL": true, "SERIAL2": true, "SERIAL4": true, "SERIAL8": true,
+}
+
+// columnDataType returns the DataType storing the values of a column of type ct, or false if the type of the column is unknown
+func columnDataType(ct *sql.ColumnType) (DataType, bool) {
+	// the size and sign of the type are ignored, e.g. "INT(11) UNSIGNED" is an INT
+	words := make([]string, 0)
+	for _, word := range strings.FieldsFunc(strings.ToUpper(ct.DatabaseTypeName()), func(r rune) bool { return r == ' ' || r == '(' || r == ')' }) {
+		if word != "SIGNED" && word != "UNSIGNED" && (word[0] < '0' || word[0] > '9') {
+			words = append(words, word)
+		}
+	}
+	name := strings.Join(words, " ")
+
+	t := ct.ScanType()
+	integerScanType := t == reflect.TypeOf(sql.NullInt64{}) || t == reflect.TypeOf(sql.NullInt32{}) || t == reflect.TypeOf(sql.NullInt16{}) ||
+		t == reflect.TypeOf(sql.NullByte{}) || t != nil && t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64
+	switch {
+	case integerTypeNames[name]:
+		return DataTypeInt, true
+	case integerScanType && !strings.Contains(name, "INT"):
+		// the sqlite driver scans any type containing "INT" as integers, e.g. POINT, following the SQLite type affinity rules
+		return DataTypeInt, true
+	case t == reflect.TypeOf(sql.NullFloat64{}), t != nil && (t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64):
+		return DataTypeFloat, true
+	case t == reflect.TypeOf(sql.NullBool{}), t != nil && t.Kind() == reflect.Bool:
+		return DataTypeBool, true
+	case t == reflect.TypeOf(sql.NullTime{}), t == reflect.TypeOf(time.Time{}):
+		return DataTypeTimestamp, true
+	}
+
+	// drivers returning text values scan some numeric types as bytes, e.g. NUMERIC on postgres or DECIMAL on MySQL
+	switch {
+	case name == "":
+		return "", false
+	case strings.Contains(name, "NUMERIC"), strings.Contains(name, "DECIMAL"), strings.Contains(name, "FLOAT"),
+		strings.Contains(name, "DOUBLE"), strings.Contains(name, "REAL"):
+		return DataTypeFloat, true
+	case strings.Contains(name, "BOOL"):
+		return DataTypeBool, true
+	}
+	return DataTypeString, true
+}
+
+// valueDataType returns the DataType storing value, a non-NULL value scanned from sql.Rows
+func valueDataType(value interface{}) DataType {
+	switch value.(type) {
+	case int64:
+		return DataTypeInt
+	case float64:
+		return DataTypeFloat
+	case bool:
+		return DataTypeBool
+	case time.Time:
+		return DataTypeTimestamp
+	}
+	return DataTypeString
+}
+
+// newSQLColumn returns an empty column of type typ named after ct, whose database type is stored in its metadata
+func newSQLColumn(ct *sql.ColumnType, typ DataType) (*DataColumn, error) {
+	column, err := NewDataColumn(ct.Name(), typ)
+	if err != nil {
+		return nil, err
+	}
+	if sqlType := ct.DatabaseTypeName(); sqlType != "" {
+		column.Metadata = map[string]string{MetadataSQLType: sqlType}
+	}
+	return column, nil
+}
+
+// appendNull appends a NULL value to column according to opts.NullPolicy: it is replaced by opts.Sentinel in integer and float columns
+// with NullPolicySentinel, and its float value is NaN with NullPolicyNaN
+func appendNull(column *DataColumn, opts *DataObjectOptions) {
+	switch {
+	case opts.NullPolicy == NullPolicySentinel && column.Type == DataTypeInt:
+		column.Ints = append(column.Ints, int64(opts.Sentinel))
+	case opts.NullPolicy == NullPolicySentinel && column.Type == DataTypeFloat:
+		column.Floats = append(column.Floats, opts.Sentinel)
+	case opts.NullPolicy == NullPolicyNaN && column.Type == DataTypeFloat:
+		column.AppendNull()
+		column.Floats[len(column.Floats)-1] = math.NaN()
+	default:
+		column.AppendNull()
+	}
+}
@@ -0,0 +1,91 @@
+package sdk_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestRetrieveDataObject(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 2)
+	_, err := db.Exec(insertQuery, nil, nil, 60.5, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	query := "SELECT age, weight, height FROM patients ORDER BY age"
+
+	// NULL values fail by default, the NULL age being first
+	_, err = db.RetrieveDataObject(ctx, nil, query)
+	require.EqualError(t, err, "reading row 1: column age is NULL")
+
+	do, err := db.RetrieveDataObject(ctx, &sdk.DataObjectOptions{NullPolicy: sdk.NullPolicyDropRow}, query)
+	require.NoError(t, err)
+	require.Equal(t, []string{"age", "weight", "height"}, do.Columns)
+	require.Equal(t, [][]float64{{age, weight, height}, {age + 1, weight, height}}, do.FloatMatrix)
+	require.Equal(t, sdk.DataTypeInt, do.Table.Column("age").Type)
+	require.Equal(t, "INT", do.Table.Column("age").Metadata[sdk.MetadataSQLType])
+
+	do, err = db.RetrieveDataObject(ctx, &sdk.DataObjectOptions{NullPolicy: sdk.NullPolicySentinel, Sentinel: -1}, query)
+	require.NoError(t, err)
+	require.Equal(t, []float64{-1, 60.5, -1}, do.FloatMatrix[0])
+	require.Zero(t, do.Table.Column("age").Nulls.Count())
+
+	do, err = db.RetrieveDataObject(ctx, &sdk.DataObjectOptions{NullPolicy: sdk.NullPolicyNaN}, query)
+	require.NoError(t, err)
+	require.True(t, math.IsNaN(do.FloatMatrix[0][0]))
+	require.True(t, math.IsNaN(do.Table.Column("height").Floats[0]))
+	require.True(t, do.Table.Column("age").IsNull(0))
+
+	_, err = db.RetrieveDataObject(ctx, &sdk.DataObjectOptions{NullPolicy: "zero"}, query)
+	require.Error(t, err)
+
+	// integer columns are stored in IntMatrix, expressions are typed after their values
+	do, err = db.RetrieveDataObject(ctx, nil, "SELECT age, COUNT(*) AS count FROM patients WHERE age >= ? GROUP BY age", age)
+	require.NoError(t, err)
+	require.Equal(t, [][]int64{{age, 1}, {age + 1, 1}}, do.IntMatrix)
+	require.Nil(t, do.FloatMatrix)
+
+	// the dropped rows are not appended to the expressions typed after their first non-NULL value
+	do, err = db.RetrieveDataObject(ctx, &sdk.DataObjectOptions{NullPolicy: sdk.NullPolicyDropRow},
+		"SELECT NULLIF(age, ?) AS younger, weight * 2 AS double FROM patients ORDER BY age", age+1)
+	require.NoError(t, err)
+	require.Equal(t, [][]float64{{age, weight * 2}}, do.FloatMatrix)
+	require.Equal(t, sdk.DataTypeInt, do.Table.Column("younger").Type)
+}
+
+func TestReadDataObjectTypes(t *testing.T) {
+	db := newTestDatabase(t, sdk.DBManagerConfig{}, sdk.SQLiteConfig{}, 0)
+	_, err := db.Exec("CREATE TABLE visits (patient VARCHAR(64), smoker BOOLEAN, date DATETIME, ward TEXT, location POINT, room UNSIGNED BIG INT)")
+	require.NoError(t, err)
+	date := time.Date(2023, time.March, 14, 10, 30, 0, 0, time.UTC)
+	_, err = db.Exec("INSERT INTO visits VALUES (?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?)",
+		name, true, date, "cardiology", "POINT(6.6 46.5)", 12, nil, false, date, "oncology", "POINT(8.5 47.4)", 7)
+	require.NoError(t, err)
+
+	rows, err := db.Retrieve("SELECT * FROM visits")
+	require.NoError(t, err)
+	do, err := sdk.ReadDataObject(rows, &sdk.DataObjectOptions{
+		NullPolicy: sdk.NullPolicyNaN,
+		Types:      map[string]sdk.DataType{"ward": sdk.DataTypeCategorical},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"patient", "smoker", "date", "ward", "location", "room"}, do.Columns)
+	require.Nil(t, do.IntMatrix)
+	require.Nil(t, do.FloatMatrix)
+
+	table := do.Table
+	require.Equal(t, 2, table.NumRows())
+	require.Equal(t, sdk.DataTypeString, table.Column("patient").Type)
+	require.Equal(t, name, table.Column("patient").Value(0))
+	require.Nil(t, table.Column("patient").Value(1))
+	require.Equal(t, []bool{true, false}, table.Column("smoker").Bools)
+	require.True(t, date.Equal(table.Column("date").Timestamps[1]))
+	require.Equal(t, []string{"cardiology", "oncology"}, table.Column("ward").Categories)
+	// only whole integer type names make integer columns
+	require.Equal(t, sdk.DataTypeString, table.Column("location").Type)
+	require.Equal(t, "POINT(8.5 47.4)", table.Column("location").Value(1))
+	require.Equal(t, []int64{12, 7}, table.Column("room").Ints)
+}
@@ -0,0 +1,423 @@
+package sdk
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+// DataType is the type of the values of a DataColumn
+type DataType string
+
+const (
+	// DataTypeInt is the type of integer columns, stored in DataColumn.Ints
+	DataTypeInt DataType = "int"
+	// DataTypeFloat is the type of floating-point columns, stored in DataColumn.Floats
+	DataTypeFloat DataType = "float"
+	// DataTypeString is the type of text columns, stored in DataColumn.Strings
+	DataTypeString DataType = "string"
+	// DataTypeBool is the type of boolean columns, stored in DataColumn.Bools
+	DataTypeBool DataType = "bool"
+	// DataTypeTimestamp is the type of date and time columns, stored in DataColumn.Timestamps
+	DataTypeTimestamp DataType = "timestamp"
+	// DataTypeCategorical is the type of columns with a small set of distinct text values,
+	// stored as codes in DataColumn.Codes indexing DataColumn.Categories
+	DataTypeCategorical DataType = "categorical"
+)
+
+// NullBitmap marks the NULL values of a DataColumn, the bit i being set if the value of row i is NULL.
+// A nil NullBitmap means that the column has no NULL values.
+type NullBitmap []uint64
+
+// IsNull returns whether the value of row i is NULL
+func (b NullBitmap) IsNull(i int) bool {
+	return i/64 < len(b) && b[i/64]&(1<<(uint(i)%64)) != 0
+}
+
+// SetNull marks the value of row i as NULL
+func (b *NullBitmap) SetNull(i int) {
+	for len(*b) <= i/64 {
+		*b = append(*b, 0)
+	}
+	(*b)[i/64] |= 1 << (uint(i) % 64)
+}
+
+// Count returns the number of NULL values
+func (b NullBitmap) Count() int {
+	count := 0
+	for _, word := range b {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// clear marks the value of row i as not NULL
+func (b NullBitmap) clear(i int) {
+	if i/64 < len(b) {
+		b[i/64] &^= 1 << (uint(i) % 64)
+	}
+}
+
+// DataColumn is a typed column of a DataTable. Its values are stored in the slice of its Type, the others being left to nil,
+// and NULL values are stored as the zero value of the type and marked in Nulls.
+type DataColumn struct {
+	Name string
+	Type DataType
+	// Metadata holds free-form information about the column, e.g. its unit, description or SQL type
+	Metadata map[string]string
+
+	Ints       []int64
+	Floats     []float64
+	Strings    []string
+	Bools      []bool
+	Timestamps []time.Time
+	// Categories are the distinct values of a categorical column, and Codes the index in Categories of the value of each row
+	Categories []string
+	Codes      []int32
+
+	Nulls NullBitmap
+
+	// categoryCodes indexes Categories, it is built when the first value is appended
+	categoryCodes map[string]int32
+}
+
+// NewDataColumn returns an empty column of type typ
+func NewDataColumn(name string, typ DataType) (*DataColumn, error) {
+	switch typ {
+	case DataTypeInt, DataTypeFloat, DataTypeString, DataTypeBool, DataTypeTimestamp, DataTypeCategorical:
+		return &DataColumn{Name: name, Type: typ}, nil
+	}
+	return nil, fmt.Errorf("column %v has unknown data type %q", name, typ)
+}
+
+// Len returns the number of values of the column
+func (c *DataColumn) Len() int {
+	switch c.Type {
+	case DataTypeInt:
+		return len(c.Ints)
+	case DataTypeFloat:
+		return len(c.Floats)
+	case DataTypeString:
+		return len(c.Strings)
+	case DataTypeBool:
+		return len(c.Bools)
+	case DataTypeTimestamp:
+		return len(c.Timestamps)
+	case DataTypeCategorical:
+		return len(c.Codes)
+	}
+	return 0
+}
+
+// IsNull returns whether the value of row i is NULL
+func (c *DataColumn) IsNull(i int) bool {
+	return c.Nulls.IsNull(i)
+}
+
+// Value returns the value of row i, nil if it is NULL. Categorical values are returned as their category.
+func (c *DataColumn) Value(i int) interface{} {
+	if c.IsNull(i) {
+		return nil
+	}
+	switch c.Type {
+	case DataTypeInt:
+		return c.Ints[i]
+	case DataTypeFloat:
+		return c.Floats[i]
+	case DataTypeString:
+		return c.Strings[i]
+	case DataTypeBool:
+		return c.Bools[i]
+	case DataTypeTimestamp:
+		return c.Timestamps[i]
+	case DataTypeCategorical:
+		return c.Categories[c.Codes[i]]
+	}
+	return nil
+}
+
+// Append appends value to the column, converted to its type with the conversions of sql.Rows.Scan.
+// nil values are appended as NULL. Timestamps can also be given as RFC 3339 strings.
+func (c *DataColumn) Append(value interface{}) error {
+	if value == nil {
+		c.AppendNull()
+		return nil
+	}
+	var err error
+	switch c.Type {
+	case DataTypeInt:
+		var v int64
+		if err = convertAssign(&v, value); err == nil {
+			c.Ints = append(c.Ints, v)
+		}
+	case DataTypeFloat:
+		var v float64
+		if err = convertAssign(&v, value); err == nil {
+			c.Floats = append(c.Floats, v)
+		}
+	case DataTypeString:
+		var v string
+		if err = convertAssign(&v, value); err == nil {
+			c.Strings = append(c.Strings, v)
+		}
+	case DataTypeBool:
+		var v bool
+		if err = convertAssign(&v, value); err == nil {
+			c.Bools = append(c.Bools, v)
+		}
+	case DataTypeTimestamp:
+		var v time.Time
+		if err = convertAssign(&v, value); err != nil {
+			var s string
+			if convertAssign(&s, value) == nil {
+				v, err = time.Parse(time.RFC3339Nano, s)
+			}
+		}
+		if err == nil {
+			c.Timestamps = append(c.Timestamps, v)
+		}
+	case DataTypeCategorical:
+		var v string
+		if err = convertAssign(&v, value); err == nil {
+			c.Codes = append(c.Codes, c.categoryCode(v))
+		}
+	default:
+		err = fmt.Errorf("unknown data type %q", c.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("appending %T value to %v column %v: %w", value, c.Type, c.Name, err)
+	}
+	return nil
+}
+
+// AppendNull appends a NULL value to the column
+func (c *DataColumn) AppendNull() {
+	c.Nulls.SetNull(c.Len())
+	switch c.Type {
+	case DataTypeInt:
+		c.Ints = append(c.Ints, 0)
+	case DataTypeFloat:
+		c.Floats = append(c.Floats, 0)
+	case DataTypeString:
+		c.Strings = append(c.Strings, "")
+	case DataTypeBool:
+		c.Bools = append(c.Bools, false)
+	case DataTypeTimestamp:
+		c.Timestamps = append(c.Timestamps, time.Time{})
+	case DataTypeCategorical:
+		c.Codes = append(c.Codes, 0)
+	}
+}
+
+// categoryCode returns the code of category, which is added to the categories of the column if it is new
+func (c *DataColumn) categoryCode(category string) int32 {
+	if c.categoryCodes == nil {
+		c.categoryCodes = make(map[string]int32, len(c.Categories))
+		for i, existing := range c.Categories {
+			c.categoryCodes[existing] = int32(i)
+		}
+	}
+	code, ok := c.categoryCodes[category]
+	if !ok {
+		code = int32(len(c.Categories))
+		c.Categories = append(c.Categories, category)
+		c.categoryCodes[category] = code
+	}
+	return code
+}
+
+// truncate removes the values of the column after the first n ones
+func (c *DataColumn) truncate(n int) {
+	for i := n; i < c.Len(); i++ {
+		c.Nulls.clear(i)
+	}
+	switch c.Type {
+	case DataTypeInt:
+		c.Ints = c.Ints[:n]
+	case DataTypeFloat:
+		c.Floats = c.Floats[:n]
+	case DataTypeString:
+		c.Strings = c.Strings[:n]
+	case DataTypeBool:
+		c.Bools = c.Bools[:n]
+	case DataTypeTimestamp:
+		c.Timestamps = c.Timestamps[:n]
+	case DataTypeCategorical:
+		c.Codes = c.Codes[:n]
+	}
+}
+
+// DataTable is a columnar table of typed values, all of its columns having the same number of rows
+type DataTable struct {
+	Columns []*DataColumn
+}
+
+// NewDataTable returns a table made of columns, which must have distinct names and the same number of values
+func NewDataTable(columns ...*DataColumn) (*DataTable, error) {
+	names := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if names[column.Name] {
+			return nil, fmt.Errorf("duplicate column %v", column.Name)
+		}
+		names[column.Name] = true
+		if column.Len() != columns[0].Len() {
+			return nil, fmt.Errorf("column %v has %v values instead of %v", column.Name, column.Len(), columns[0].Len())
+		}
+	}
+	return &DataTable{Columns: columns}, nil
+}
+
+// NumRows returns the number of rows of the table
+func (t *DataTable) NumRows() int {
+	if len(t.Columns) == 0 {
+		return 0
+	}
+	return t.Columns[0].Len()
+}
+
+// Column returns the column named name, nil if there is none
+func (t *DataTable) Column(name string) *DataColumn {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// ColumnNames returns the names of the columns of the table, in their order
+func (t *DataTable) ColumnNames() []string {
+	names := make([]string, len(t.Columns))
+	for i, column := range t.Columns {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// AppendRow appends a row of values, one per column, see DataColumn.Append. The table is left unchanged if a value cannot be appended.
+func (t *DataTable) AppendRow(values ...interface{}) error {
+	if len(values) != len(t.Columns) {
+		return fmt.Errorf("appending row of %v values to table of %v columns", len(values), len(t.Columns))
+	}
+	n := t.NumRows()
+	for i, value := range values {
+		if err := t.Columns[i].Append(value); err != nil {
+			for _, column := range t.Columns[:i] {
+				column.truncate(n)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// IntMatrix returns the rows of the table as integers. All the columns must be integer columns without NULL values.
+func (t *DataTable) IntMatrix() ([][]int64, error) {
+	for _, column := range t.Columns {
+		if column.Type != DataTypeInt {
+			return nil, fmt.Errorf("column %v of type %v cannot be converted to integers", column.Name, column.Type)
+		}
+		if column.Nulls.Count() > 0 {
+			return nil, fmt.Errorf("column %v has NULL values", column.Name)
+		}
+	}
+	matrix := make([][]int64, t.NumRows())
+	for i := range matrix {
+		matrix[i] = make([]int64, len(t.Columns))
+		for j, column := range t.Columns {
+			matrix[i][j] = column.Ints[i]
+		}
+	}
+	return matrix, nil
+}
+
+// FloatMatrix returns the rows of the table as floating-point numbers. All the columns must be numeric or boolean columns,
+// booleans being converted to 0 and 1, and NULL values are converted to NaN.
+func (t *DataTable) FloatMatrix() ([][]float64, error) {
+	for _, column := range t.Columns {
+		if !column.Type.numeric() {
+			return nil, fmt.Errorf("column %v of type %v cannot be converted to floats", column.Name, column.Type)
+		}
+	}
+	matrix := make([][]float64, t.NumRows())
+	for i := range matrix {
+		matrix[i] = make([]float64, len(t.Columns))
+		for j, column := range t.Columns {
+			switch {
+			case column.IsNull(i):
+				matrix[i][j] = math.NaN()
+			case column.Type == DataTypeInt:
+				matrix[i][j] = float64(column.Ints[i])
+			case column.Type == DataTypeFloat:
+				matrix[i][j] = column.Floats[i]
+			case column.Bools[i]:
+				matrix[i][j] = 1
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// numeric returns whether the values of the type can be converted to floats
+func (typ DataType) numeric() bool {
+	return typ == DataTypeInt || typ == DataTypeFloat || typ == DataTypeBool
+}
+
+// SetTable sets the table of the DataObject and its columns. For the plugins reading the matrices of DataObjects, the rows are also
+// stored in IntMatrix if all the columns are integers without NULL values, or else in FloatMatrix if they are all numeric, see DataTable.FloatMatrix.
+func (do *DataObject) SetTable(t *DataTable) {
+	do.Table = t
+	do.Columns = t.ColumnNames()
+	do.IntMatrix, do.FloatMatrix = nil, nil
+	if matrix, err := t.IntMatrix(); err == nil {
+		do.IntMatrix = matrix
+	} else if matrix, err := t.FloatMatrix(); err == nil {
+		do.FloatMatrix = matrix
+	}
+}
+
+// AsTable returns the table of the DataObject. If it has none, a table is built from IntMatrix or FloatMatrix,
+// with integer or float columns named after Columns.
+func (do *DataObject) AsTable() (*DataTable, error) {
+	if do.Table != nil {
+		return do.Table, nil
+	}
+	if do.IntMatrix != nil && do.FloatMatrix != nil {
+		return nil, fmt.Errorf("data object has both an integer and a float matrix")
+	}
+	typ := DataTypeInt
+	rows := len(do.IntMatrix)
+	if do.FloatMatrix != nil {
+		typ, rows = DataTypeFloat, len(do.FloatMatrix)
+	}
+
+	columns := make([]*DataColumn, len(do.Columns))
+	for j, name := range do.Columns {
+		columns[j] = &DataColumn{Name: name, Type: typ}
+		if typ == DataTypeInt {
+			columns[j].Ints = make([]int64, rows)
+		} else {
+			columns[j].Floats = make([]float64, rows)
+		}
+	}
+	for i := 0; i < rows; i++ {
+		var width int
+		if typ == DataTypeInt {
+			width = len(do.IntMatrix[i])
+		} else {
+			width = len(do.FloatMatrix[i])
+		}
+		if width != len(columns) {
+			return nil, fmt.Errorf("row %v has %v values instead of %v", i, width, len(columns))
+		}
+		for j, column := range columns {
+			if typ == DataTypeInt {
+				column.Ints[i] = do.IntMatrix[i][j]
+			} else {
+				column.Floats[i] = do.FloatMatrix[i][j]
+			}
+		}
+	}
+	return NewDataTable(columns...)
+}
@@ -0,0 +1,103 @@
+package sdk_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tuneinsight/sdk-datasource/pkg/sdk"
+)
+
+func TestDataTable(t *testing.T) {
+	newColumn := func(name string, typ sdk.DataType) *sdk.DataColumn {
+		column, err := sdk.NewDataColumn(name, typ)
+		require.NoError(t, err)
+		return column
+	}
+	_, err := sdk.NewDataColumn("unknown", "decimal")
+	require.Error(t, err)
+
+	birth := time.Date(1879, time.March, 14, 0, 0, 0, 0, time.UTC)
+	table, err := sdk.NewDataTable(newColumn("name", sdk.DataTypeString), newColumn("age", sdk.DataTypeInt),
+		newColumn("weight", sdk.DataTypeFloat), newColumn("smoker", sdk.DataTypeBool),
+		newColumn("birth", sdk.DataTypeTimestamp), newColumn("sex", sdk.DataTypeCategorical))
+	require.NoError(t, err)
+	require.NoError(t, table.AppendRow(name, age, weight, false, birth, "M"))
+	require.NoError(t, table.AppendRow([]byte("Marie Curie"), "66", nil, int64(1), "1867-11-07T00:00:00Z", "F"))
+	require.NoError(t, table.AppendRow(nil, nil, 60.5, nil, nil, "F"))
+
+	// a row which cannot be converted leaves the table unchanged
+	require.Error(t, table.AppendRow("Niels Bohr", "not a number", 70.0, true, birth, "M"))
+	require.Error(t, table.AppendRow(name))
+	require.Equal(t, 3, table.NumRows())
+	require.Equal(t, []string{"name", "age", "weight", "smoker", "birth", "sex"}, table.ColumnNames())
+
+	ages := table.Column("age")
+	require.Equal(t, []int64{age, 66, 0}, ages.Ints)
+	require.Equal(t, 1, ages.Nulls.Count())
+	require.True(t, ages.IsNull(2))
+	require.Nil(t, ages.Value(2))
+	require.Equal(t, "Marie Curie", table.Column("name").Value(1))
+	require.Equal(t, true, table.Column("smoker").Value(1))
+	require.True(t, table.Column("birth").Value(1).(time.Time).Equal(time.Date(1867, time.November, 7, 0, 0, 0, 0, time.UTC)))
+	sex := table.Column("sex")
+	require.Equal(t, []string{"M", "F"}, sex.Categories)
+	require.Equal(t, []int32{0, 1, 1}, sex.Codes)
+	require.Equal(t, "F", sex.Value(2))
+	require.Nil(t, table.Column("unknown"))
+
+	// columns must have distinct names and the same number of values
+	_, err = sdk.NewDataTable(ages, newColumn("age", sdk.DataTypeInt))
+	require.Error(t, err)
+	_, err = sdk.NewDataTable(ages, newColumn("height", sdk.DataTypeFloat))
+	require.Error(t, err)
+}
+
+func TestDataTableMatrices(t *testing.T) {
+	ages := &sdk.DataColumn{Name: "age", Type: sdk.DataTypeInt, Ints: []int64{58, 66}}
+	weights := &sdk.DataColumn{Name: "weight", Type: sdk.DataTypeFloat, Floats: []float64{76.8, 0}}
+	weights.Nulls.SetNull(1)
+	smokers := &sdk.DataColumn{Name: "smoker", Type: sdk.DataTypeBool, Bools: []bool{true, false}}
+
+	table, err := sdk.NewDataTable(ages)
+	require.NoError(t, err)
+	do := sdk.DataObject{}
+	do.SetTable(table)
+	require.Equal(t, []string{"age"}, do.Columns)
+	require.Equal(t, [][]int64{{58}, {66}}, do.IntMatrix)
+	require.Nil(t, do.FloatMatrix)
+
+	table, err = sdk.NewDataTable(ages, weights, smokers)
+	require.NoError(t, err)
+	_, err = table.IntMatrix()
+	require.Error(t, err)
+	do.SetTable(table)
+	require.Nil(t, do.IntMatrix)
+	require.Equal(t, []float64{58, 76.8, 1}, do.FloatMatrix[0])
+	require.Equal(t, float64(66), do.FloatMatrix[1][0])
+	require.True(t, math.IsNaN(do.FloatMatrix[1][1]))
+	same, err := do.AsTable()
+	require.NoError(t, err)
+	require.Same(t, table, same)
+
+	// text columns are only available in the table
+	table.Columns = append(table.Columns, &sdk.DataColumn{Name: "name", Type: sdk.DataTypeString, Strings: []string{"a", "b"}})
+	_, err = table.FloatMatrix()
+	require.Error(t, err)
+	do.SetTable(table)
+	require.Nil(t, do.IntMatrix)
+	require.Nil(t, do.FloatMatrix)
+	require.Len(t, do.Columns, 4)
+
+	// legacy data objects are converted to tables
+	legacy := sdk.DataObject{Columns: []string{"age", "height"}, FloatMatrix: [][]float64{{58, 180.3}, {66, 160}}}
+	table, err = legacy.AsTable()
+	require.NoError(t, err)
+	require.Equal(t, sdk.DataTypeFloat, table.Column("height").Type)
+	require.Equal(t, []float64{180.3, 160}, table.Column("height").Floats)
+
+	legacy = sdk.DataObject{Columns: []string{"age"}, IntMatrix: [][]int64{{58}, {66, 1}}}
+	_, err = legacy.AsTable()
+	require.Error(t, err)
+}